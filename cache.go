@@ -0,0 +1,98 @@
+package zaphandler
+
+import (
+	"container/list"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	cacheShards = 16
+	cacheSize   = 1024
+
+	// fibonacci hashing spreads the program counters of neighbouring call
+	// sites over the shards.
+	cacheHash = 11400714819323198485
+)
+
+type (
+	cacheItem struct {
+		pc     uintptr
+		caller zapcore.EntryCaller
+	}
+	cacheShard struct {
+		sync.Mutex
+		size  int
+		items map[uintptr]*list.Element
+		order list.List
+	}
+	callerCache struct{ shards [cacheShards]cacheShard }
+)
+
+// newCallerCache returns a LRU cache of resolved callers holding around size
+// program counters, or nil if size is not positive.
+func newCallerCache(size int) *callerCache {
+	if size < 1 {
+		return nil
+	}
+
+	shardSize := (size + cacheShards - 1) / cacheShards
+	cache := &callerCache{}
+
+	for i := range cache.shards {
+		cache.shards[i].size = shardSize
+		cache.shards[i].items = make(map[uintptr]*list.Element, shardSize)
+	}
+
+	return cache
+}
+
+func (c *callerCache) shard(pc uintptr) *cacheShard {
+	return &c.shards[(uint64(pc)*cacheHash)>>60%cacheShards]
+}
+
+func (c *callerCache) get(pc uintptr) (zapcore.EntryCaller, bool) {
+	if c == nil {
+		return zapcore.EntryCaller{}, false
+	}
+
+	shard := c.shard(pc)
+
+	shard.Lock()
+	defer shard.Unlock()
+
+	elem, ok := shard.items[pc]
+	if !ok {
+		return zapcore.EntryCaller{}, false
+	}
+
+	shard.order.MoveToFront(elem)
+
+	item, _ := elem.Value.(*cacheItem)
+
+	return item.caller, true
+}
+
+func (c *callerCache) put(pc uintptr, caller zapcore.EntryCaller) {
+	if c == nil {
+		return
+	}
+
+	shard := c.shard(pc)
+
+	shard.Lock()
+	defer shard.Unlock()
+
+	if _, ok := shard.items[pc]; ok {
+		return
+	}
+
+	if shard.order.Len() >= shard.size {
+		if item, ok := shard.order.Remove(shard.order.Back()).(*cacheItem); ok {
+			delete(shard.items, item.pc)
+		}
+	}
+
+	shard.items[pc] = shard.order.PushFront(&cacheItem{pc: pc, caller: caller})
+}
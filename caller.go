@@ -0,0 +1,87 @@
+package zaphandler
+
+import (
+	"path"
+	"runtime/debug"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type callerFormat struct {
+	trimPrefixes   []string
+	moduleRelative bool
+	mainPath       string
+	shortFunction  bool
+}
+
+// TrimCallerPrefix strips the first matching prefix, such as GOPATH or the
+// build directory, from caller file paths.
+func TrimCallerPrefix(prefixes ...string) Option {
+	return func(h *ZapHandler) {
+		h.pool.format.trimPrefixes = append(h.pool.format.trimPrefixes, prefixes...)
+	}
+}
+
+// ModuleRelativeCaller reports caller files under their package import path,
+// the way builds with -trimpath do. Function names only tell "main" for
+// package main, so its path comes from the build info, and stays "main" for
+// binaries without one.
+func ModuleRelativeCaller() Option {
+	return func(h *ZapHandler) {
+		h.pool.format.moduleRelative = true
+		h.pool.format.mainPath = mainPackagePath()
+	}
+}
+
+// mainPackagePath is the import path of package main, as recorded in the build
+// info.
+func mainPackagePath() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Path == "" || info.Path == "command-line-arguments" {
+		return ""
+	}
+
+	return info.Path
+}
+
+// ShortCallerFunction strips the package directory from caller function
+// names, leaving "pkg.(*T).Method".
+func ShortCallerFunction() Option { return func(h *ZapHandler) { h.pool.format.shortFunction = true } }
+
+// packagePath splits a fully qualified function name after its package path.
+func packagePath(function string) (string, string) {
+	lastSlash := strings.LastIndexByte(function, '/')
+	if dot := strings.IndexByte(function[lastSlash+1:], '.'); dot >= 0 {
+		return function[:lastSlash+1+dot], function[lastSlash+1:]
+	}
+
+	return "", function
+}
+
+func (f callerFormat) apply(caller zapcore.EntryCaller) zapcore.EntryCaller {
+	pkg, short := packagePath(caller.Function)
+
+	switch {
+	case f.moduleRelative && pkg != "":
+		if pkg == "main" && f.mainPath != "" {
+			pkg = f.mainPath
+		}
+
+		caller.File = path.Join(strings.TrimSuffix(pkg, "_test"), path.Base(caller.File))
+	default:
+		for _, prefix := range f.trimPrefixes {
+			if trimmed, ok := strings.CutPrefix(caller.File, prefix); ok {
+				caller.File = strings.TrimPrefix(trimmed, "/")
+
+				break
+			}
+		}
+	}
+
+	if f.shortFunction {
+		caller.Function = short
+	}
+
+	return caller
+}
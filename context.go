@@ -0,0 +1,29 @@
+package zaphandler
+
+import (
+	"context"
+	"log/slog"
+)
+
+type levelKey struct{}
+
+// WithLevel returns a context which sets the level of ZapHandlers for records
+// logged with it, in place of the level of their core. Records at lvl and
+// above are written even when the core is not enabled for them, which lets a
+// single request be logged at slog.LevelDebug while the rest stay at
+// slog.LevelInfo. Such records go where records at the lowest level of the
+// core go, so the cores of a tee with higher levels do not get them.
+func WithLevel(ctx context.Context, lvl slog.Level) context.Context {
+	return context.WithValue(ctx, levelKey{}, lvl)
+}
+
+// levelFrom returns the level set by WithLevel on ctx.
+func levelFrom(ctx context.Context) (slog.Level, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+
+	lvl, ok := ctx.Value(levelKey{}).(slog.Level)
+
+	return lvl, ok
+}
@@ -0,0 +1,61 @@
+package zaphandler
+
+import (
+	"fmt"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ZeroTimeClock makes records with a zero time take their time from clock
+// instead of leaving it out.
+func ZeroTimeClock(clock zapcore.Clock) Option { return func(h *ZapHandler) { h.zeroTime = clock } }
+
+// ZeroTimeNow makes records with a zero time use the current time.
+func ZeroTimeNow() Option { return ZeroTimeClock(zapcore.DefaultClock) }
+
+type zeroTimeCore struct {
+	zapcore.Core
+	noTime zapcore.Core
+}
+
+// NewCore works like zapcore.NewCore, but the time key is left out for entries
+// with a zero time, as slog expects from its handlers.
+func NewCore(
+	newEncoder func(zapcore.EncoderConfig) zapcore.Encoder,
+	cfg zapcore.EncoderConfig,
+	out zapcore.WriteSyncer,
+	enab zapcore.LevelEnabler,
+) zapcore.Core {
+	noTime := cfg
+	noTime.TimeKey = ""
+
+	return &zeroTimeCore{
+		Core:   zapcore.NewCore(newEncoder(cfg), out, enab),
+		noTime: zapcore.NewCore(newEncoder(noTime), out, enab),
+	}
+}
+
+func (c *zeroTimeCore) With(fields []zapcore.Field) zapcore.Core {
+	return &zeroTimeCore{Core: c.Core.With(fields), noTime: c.noTime.With(fields)}
+}
+
+func (c *zeroTimeCore) Check(ent zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return checked.AddCore(ent, c)
+	}
+
+	return checked
+}
+
+func (c *zeroTimeCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	core := c.Core
+	if ent.Time.IsZero() {
+		core = c.noTime
+	}
+
+	if err := core.Write(ent, fields); err != nil {
+		return fmt.Errorf("error from core: %w", err)
+	}
+
+	return nil
+}
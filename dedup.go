@@ -0,0 +1,162 @@
+package zaphandler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// RepeatedKey holds the number of records a DedupHandler left out since the
+// last one it let through.
+const RepeatedKey = "repeated"
+
+type (
+	dedupKey struct {
+		level slog.Level
+		msg   string
+		pc    uintptr
+	}
+	dedupEntry struct {
+		seen, repeated int
+		hand           *ZapHandler
+		rec            slog.Record
+		timer          *time.Timer
+	}
+	dedupState struct {
+		sync.Mutex
+		window  time.Duration
+		first   int
+		entries map[dedupKey]*dedupEntry
+	}
+)
+
+var _ slog.Handler = (*DedupHandler)(nil)
+
+// DedupHandler keeps floods of the same record from reaching the core. Records
+// with the same level, message and call site are let through the first times
+// within a window, after which each window ends with a summary record carrying
+// the count of the left out ones under RepeatedKey, until a window passes
+// without repetitions. Summaries are written by the ZapHandler of the last
+// repeated record, so they use the same core, attrs and groups.
+type DedupHandler struct {
+	hand  *ZapHandler
+	state *dedupState
+}
+
+// NewDedup wraps hand, letting through first records within each window. It
+// panics if window or first is not positive.
+func NewDedup(hand *ZapHandler, window time.Duration, first int) *DedupHandler {
+	if window <= 0 || first < 1 {
+		panic("zaphandler: dedup window and first count must be positive")
+	}
+
+	return &DedupHandler{
+		hand:  hand,
+		state: &dedupState{window: window, first: first, entries: map[dedupKey]*dedupEntry{}},
+	}
+}
+
+func (d *DedupHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return d.hand.Enabled(ctx, lvl)
+}
+
+func (d *DedupHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if !d.state.repeated(d.hand, rec) {
+		return d.hand.Handle(ctx, rec)
+	}
+
+	return nil
+}
+
+func (d *DedupHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	hand, _ := d.hand.WithAttrs(attrs).(*ZapHandler)
+
+	return &DedupHandler{hand: hand, state: d.state}
+}
+
+func (d *DedupHandler) WithGroup(name string) slog.Handler {
+	hand, _ := d.hand.WithGroup(name).(*ZapHandler)
+
+	return &DedupHandler{hand: hand, state: d.state}
+}
+
+// Close writes the summaries of every pending window.
+func (d *DedupHandler) Close() error {
+	d.state.Lock()
+
+	entries := d.state.entries
+	d.state.entries = map[dedupKey]*dedupEntry{}
+
+	d.state.Unlock()
+
+	var errs []error
+
+	for _, entry := range entries {
+		entry.timer.Stop()
+		errs = append(errs, entry.summary())
+	}
+
+	return errors.Join(errs...)
+}
+
+// repeated records rec, reporting whether it is one to leave out.
+func (s *dedupState) repeated(hand *ZapHandler, rec slog.Record) bool {
+	key := dedupKey{level: rec.Level, msg: rec.Message, pc: rec.PC}
+
+	s.Lock()
+	defer s.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &dedupEntry{}
+		entry.timer = time.AfterFunc(s.window, func() { s.endWindow(key, entry) })
+		s.entries[key] = entry
+	}
+
+	if entry.seen++; entry.seen <= s.first {
+		return false
+	}
+
+	entry.repeated++
+	entry.hand, entry.rec = hand, rec
+
+	return true
+}
+
+func (s *dedupState) endWindow(key dedupKey, entry *dedupEntry) {
+	s.Lock()
+
+	if s.entries[key] != entry {
+		s.Unlock()
+
+		return
+	}
+
+	if entry.repeated == 0 {
+		delete(s.entries, key)
+		s.Unlock()
+
+		return
+	}
+
+	summary := *entry
+	entry.repeated = 0
+	entry.timer.Reset(s.window)
+
+	s.Unlock()
+
+	_ = summary.summary()
+}
+
+func (e *dedupEntry) summary() error {
+	if e.repeated == 0 {
+		return nil
+	}
+
+	rec := slog.NewRecord(time.Now(), e.rec.Level, e.rec.Message, e.rec.PC)
+	rec.AddAttrs(slog.Int(RepeatedKey, e.repeated))
+
+	return e.hand.Handle(context.Background(), rec)
+}
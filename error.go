@@ -1,18 +1,172 @@
 package zaphandler
 
-import "strings"
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
 
+	"go.uber.org/zap/zapcore"
+)
+
+const writeErrorSep = " write error: "
+
+// ErrWrite matches every WriteError with errors.Is.
+var ErrWrite = errors.New("write error")
+
+// WriteError is a failure of a core to write a record. Cores from NewCore and
+// zapcore.NewCore, including those of a zapcore.NewTee, keep the error they
+// returned in Err, for errors.Is and errors.As. zap only hands over the text
+// of the errors of other cores, in the form "<time> write error: <message>",
+// which leaves Err nil.
+type WriteError struct {
+	Time    string
+	Message string
+	Err     error
+}
+
+func (e *WriteError) Error() string {
+	if e.Time == "" {
+		return e.Message
+	}
+
+	return e.Time + writeErrorSep + e.Message
+}
+
+func (e *WriteError) Is(target error) bool { return target == ErrWrite } //nolint:errorlint,goerr113
+func (e *WriteError) Unwrap() error        { return e.Err }
+
+func parseWriteError(line string) *WriteError {
+	if t, msg, ok := strings.Cut(line, writeErrorSep); ok {
+		return &WriteError{Time: t, Message: msg}
+	}
+
+	return &WriteError{Message: line}
+}
+
+// Error collects the errors of handling a single record. Write errors of the
+// cores it can wrap are collected as they are. As a zapcore.WriteSyncer it is
+// the ErrorOutput of the record's entry as well, parsing each line reported
+// for other cores into a WriteError. Other errors are kept as they are, so
+// errors.Is and errors.As reach them.
 type Error struct {
-	strings.Builder
+	errs    []error
+	partial []byte
+	cores   [2]errorCore
+	wrapped int
+}
+
+func (e *Error) Write(data []byte) (int, error) {
+	e.partial = append(e.partial, data...)
+
+	for {
+		line, rest, ok := bytes.Cut(e.partial, []byte{'\n'})
+		if !ok {
+			break
+		}
+
+		if len(line) > 0 {
+			e.errs = append(e.errs, parseWriteError(string(line)))
+		}
+
+		e.partial = rest
+	}
+
+	return len(data), nil
 }
 
-func (e *Error) Sync() error   { return nil }
-func (e *Error) Error() string { return e.String() }
+func (e *Error) Sync() error {
+	if len(e.partial) > 0 {
+		e.errs = append(e.errs, parseWriteError(string(e.partial)))
+		e.partial = nil
+	}
+
+	return nil
+}
+
+// Add collects err, ignoring nil.
+func (e *Error) Add(err error) {
+	if err != nil {
+		e.errs = append(e.errs, err)
+	}
+}
+
+func (e *Error) Error() string   { return errors.Join(e.errs...).Error() }
+func (e *Error) Unwrap() []error { return e.errs }
 
 func (e *Error) Err() error {
-	if e.Len() < 1 {
+	_ = e.Sync()
+
+	if len(e.errs) < 1 {
 		return nil
 	}
 
 	return e
 }
+
+var (
+	ioCoreType = reflect.TypeOf(zapcore.NewCore(nil, nil, nil))
+	teeType    = reflect.TypeOf(zapcore.NewTee(zapcore.NewNopCore(), zapcore.NewNopCore()))
+)
+
+// checker checks the cores of a record, allocating its Error only once a
+// core has to be wrapped.
+type checker struct{ errs *Error }
+
+// check is core.Check, except that cores writing straight to their sink are
+// added wrapped, so their errors are collected as they are. The cores of tees
+// are checked one by one for it. Other cores can not be told apart from tees,
+// so they are left as they are.
+func (c *checker) check(core zapcore.Core, ent zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	switch typ := reflect.TypeOf(core); typ {
+	case teeType:
+		tee := reflect.ValueOf(core)
+		for i := 0; i < tee.Len(); i++ {
+			if child, ok := tee.Index(i).Interface().(zapcore.Core); ok {
+				checked = c.check(child, ent, checked)
+			}
+		}
+
+		return checked
+	case ioCoreType, reflect.TypeOf((*zeroTimeCore)(nil)):
+		if core.Enabled(ent.Level) {
+			return checked.AddCore(ent, c.wrap(core))
+		}
+
+		return checked
+	default:
+		return core.Check(ent, checked)
+	}
+}
+
+func (c *checker) wrap(core zapcore.Core) *errorCore {
+	if c.errs == nil {
+		c.errs = &Error{}
+	}
+
+	errs := c.errs
+	if errs.wrapped >= len(errs.cores) {
+		return &errorCore{Core: core, errs: errs}
+	}
+
+	wrapped := &errs.cores[errs.wrapped]
+	*wrapped = errorCore{Core: core, errs: errs}
+	errs.wrapped++
+
+	return wrapped
+}
+
+// errorCore writes a record to a core, collecting its error into the Error of
+// the record instead of returning it to zap.
+type errorCore struct {
+	zapcore.Core
+	errs *Error
+}
+
+func (c *errorCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if err := c.Core.Write(ent, fields); err != nil {
+		c.errs.Add(&WriteError{Time: ent.Time.String(), Message: err.Error(), Err: err})
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package zaphandler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+type (
+	recordedT struct {
+		hand *ZapHandler
+		rec  slog.Record
+	}
+	ringT struct {
+		records []recordedT
+		start   int
+	}
+	recorderState struct {
+		sync.Mutex
+		key     func(context.Context) (any, bool)
+		size    int
+		below   slog.Level
+		flushAt slog.Level
+		rings   map[any]*ringT
+	}
+)
+
+var _ slog.Handler = (*FlightRecorder)(nil)
+
+// FlightRecorder keeps records below a level in memory per request instead of
+// writing them. Requests are told apart by a key taken from the context, and
+// each keeps its last records in a ring of a fixed size. A record at the flush
+// level writes the kept records of its request in order ahead of itself, past
+// the level of the core the way WithLevel does, so the cores of a tee with
+// higher levels do not get them. Kept records are dropped by Discard, which is
+// to be called when a request ends. Records without a key in their context go
+// straight to the ZapHandler.
+type FlightRecorder struct {
+	hand  *ZapHandler
+	state *recorderState
+}
+
+// NewFlightRecorder wraps hand, keeping up to size records below the level
+// below for each key, and writing them on records at flushAt or above.
+func NewFlightRecorder(
+	hand *ZapHandler,
+	key func(context.Context) (any, bool),
+	size int,
+	below, flushAt slog.Level,
+) *FlightRecorder {
+	return &FlightRecorder{
+		hand: hand,
+		state: &recorderState{
+			key:     key,
+			size:    size,
+			below:   below,
+			flushAt: flushAt,
+			rings:   map[any]*ringT{},
+		},
+	}
+}
+
+func (f *FlightRecorder) Enabled(ctx context.Context, lvl slog.Level) bool {
+	if lvl < f.state.below && f.state.size > 0 {
+		if _, ok := f.state.key(ctx); ok {
+			return true
+		}
+	}
+
+	return f.hand.Enabled(ctx, lvl)
+}
+
+func (f *FlightRecorder) Handle(ctx context.Context, rec slog.Record) error {
+	key, ok := f.state.key(ctx)
+	if !ok {
+		return f.hand.Handle(ctx, rec)
+	}
+
+	if rec.Level < f.state.below {
+		if f.state.size > 0 {
+			// Callers are skipped now, as the stack is gone by the time the
+			// record is written, leaving the call site as it is.
+			if f.hand.usesCaller() || f.hand.reflected != nil {
+				rec.PC = f.hand.pool.skipCallers(rec.PC, f.hand.callerSkip)
+			}
+			f.state.add(key, recordedT{hand: f.hand, rec: rec.Clone()})
+		}
+
+		return nil
+	}
+
+	if rec.Level < f.state.flushAt {
+		return f.hand.Handle(ctx, rec)
+	}
+
+	var errs []error
+
+	for _, recorded := range f.state.take(key) {
+		errs = append(errs, recorded.hand.forceHandle(ctx, recorded.rec))
+	}
+
+	errs = append(errs, f.hand.Handle(ctx, rec))
+
+	return errors.Join(errs...)
+}
+
+func (f *FlightRecorder) WithAttrs(attrs []slog.Attr) slog.Handler {
+	hand, _ := f.hand.WithAttrs(attrs).(*ZapHandler)
+
+	return &FlightRecorder{hand: hand, state: f.state}
+}
+
+func (f *FlightRecorder) WithGroup(name string) slog.Handler {
+	hand, _ := f.hand.WithGroup(name).(*ZapHandler)
+
+	return &FlightRecorder{hand: hand, state: f.state}
+}
+
+// Discard drops the records kept for the request of ctx.
+func (f *FlightRecorder) Discard(ctx context.Context) {
+	key, ok := f.state.key(ctx)
+	if !ok {
+		return
+	}
+
+	f.state.Lock()
+	delete(f.state.rings, key)
+	f.state.Unlock()
+}
+
+// add keeps recorded for key, overwriting the oldest record once the ring is
+// full.
+func (s *recorderState) add(key any, recorded recordedT) {
+	s.Lock()
+	defer s.Unlock()
+
+	ring, ok := s.rings[key]
+	if !ok {
+		ring = &ringT{records: make([]recordedT, 0, s.size)}
+		s.rings[key] = ring
+	}
+
+	if len(ring.records) < s.size {
+		ring.records = append(ring.records, recorded)
+
+		return
+	}
+
+	ring.records[ring.start] = recorded
+	ring.start = (ring.start + 1) % s.size
+}
+
+// take removes the records kept for key, returning them oldest first.
+func (s *recorderState) take(key any) []recordedT {
+	s.Lock()
+	defer s.Unlock()
+
+	ring, ok := s.rings[key]
+	if !ok {
+		return nil
+	}
+
+	delete(s.rings, key)
+
+	return append(ring.records[ring.start:], ring.records[:ring.start]...)
+}
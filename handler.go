@@ -4,12 +4,26 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
+	"time"
 
 	"go.mrchanchal.com/zaphandler/types"
 	"go.uber.org/zap/zapcore"
 )
 
-const ErrorKey = "error"
+const (
+	ErrorKey = "error"
+	// TruncatedKey holds the number of attrs dropped from a record by
+	// MaxAttrs.
+	TruncatedKey = "truncated"
+)
+
+// LevelPanic and LevelFatal are written at zapcore.PanicLevel and
+// zapcore.FatalLevel. Only PanicLevel and FatalLevel make them panic or exit.
+const (
+	LevelPanic = slog.Level(12)
+	LevelFatal = slog.Level(16)
+)
 
 func level(lvl slog.Level) (zapcore.Level, bool) {
 	val, found := map[slog.Level]zapcore.Level{
@@ -17,20 +31,111 @@ func level(lvl slog.Level) (zapcore.Level, bool) {
 		slog.LevelInfo:  zapcore.InfoLevel,
 		slog.LevelWarn:  zapcore.WarnLevel,
 		slog.LevelError: zapcore.ErrorLevel,
+		LevelPanic:      zapcore.PanicLevel,
+		LevelFatal:      zapcore.FatalLevel,
 	}[lvl]
 
 	return val, found
 }
 
-type Option func(*ZapHandler)
+type (
+	Option     func(*ZapHandler)
+	levelHookT struct {
+		level slog.Level
+		hook  zapcore.CheckWriteHook
+	}
+)
 
 func AddSource() Option { return func(h *ZapHandler) { h.AddSource = true } }
 
+// CallerCacheSize sets how many resolved call sites are kept when AddSource
+// is set. A size below one turns the cache off.
+func CallerCacheSize(size int) Option {
+	return func(h *ZapHandler) { h.pool.callers = newCallerCache(size) }
+}
+
+// CallerSkip reports the caller skip frames above the one of the record, so
+// logging facades wrapping a *slog.Logger can point at their own callers.
+func CallerSkip(skip int) Option { return func(h *ZapHandler) { h.callerSkip += skip } }
+
+// SourceField adds the caller as a structured slog.Source object under key,
+// whether or not the encoder has a CallerKey.
+func SourceField(key string) Option { return func(h *ZapHandler) { h.sourceKey = key } }
+
+// LevelHook runs hook after writing records at lvl or above. The hook of the
+// highest matching level wins. Records are checked even if the core is not
+// enabled for them, so that the hook still runs.
+func LevelHook(lvl slog.Level, hook zapcore.CheckWriteHook) Option {
+	return func(h *ZapHandler) {
+		h.levelHooks = append(slices.Clip(h.levelHooks), levelHookT{level: lvl, hook: hook})
+		slices.SortStableFunc(h.levelHooks, func(a, b levelHookT) int { return int(b.level - a.level) })
+	}
+}
+
+// PanicLevel makes records at lvl or above panic after being written at
+// zapcore.PanicLevel.
+func PanicLevel(lvl slog.Level) Option { return LevelHook(lvl, zapcore.WriteThenPanic) }
+
+// FatalLevel makes records at lvl or above exit the process after being
+// written at zapcore.FatalLevel.
+func FatalLevel(lvl slog.Level) Option { return LevelHook(lvl, zapcore.WriteThenFatal) }
+
+// ErrorHandler hands the errors of Handle to handle instead of returning
+// them, as slog.Logger drops them.
+func ErrorHandler(handle func(error)) Option { return func(h *ZapHandler) { h.errorHandler = handle } }
+
+// WithRegistry converts values with the converters of reg ahead of the ones
+// from types.Register and the built-in conversions.
+func WithRegistry(reg *types.Registry) Option { return func(h *ZapHandler) { h.conv.Registry = reg } }
+
+// MaxStringLength cuts strings longer than size bytes, marking them with
+// types.Truncated.
+func MaxStringLength(size int) Option { return func(h *ZapHandler) { h.conv.Limits.MaxString = size } }
+
+// MaxArrayLength keeps the first size elements of arrays, marking cut ones
+// with a final types.Truncated element.
+func MaxArrayLength(size int) Option { return func(h *ZapHandler) { h.conv.Limits.MaxArray = size } }
+
+// MaxGroupDepth replaces groups nested deeper than depth within an attr with
+// types.Truncated.
+func MaxGroupDepth(depth int) Option { return func(h *ZapHandler) { h.conv.Limits.MaxDepth = depth } }
+
+// MaxAttrs keeps the first size attrs of a record, counting the dropped ones
+// under TruncatedKey.
+func MaxAttrs(size int) Option { return func(h *ZapHandler) { h.conv.Limits.MaxAttrs = size } }
+
+// WithClock makes every record take its time from clock, the same way
+// zap.WithClock does for a zap.Logger.
+func WithClock(clock zapcore.Clock) Option { return func(h *ZapHandler) { h.clock = clock } }
+
+type groupT struct {
+	name   string
+	fields []zapcore.Field
+}
+
 type ZapHandler struct {
-	AddSource bool
-	groups    []string
-	core      zapcore.Core
-	pool      *poolT
+	AddSource    bool
+	callerSkip   int
+	sourceKey    string
+	levelHooks   []levelHookT
+	entryHooks   []EntryHook
+	writeHooks   []zapcore.CheckWriteHook
+	errorHandler func(error)
+	stats        *statsT
+	reflected    *reflectReportT
+	conv         types.Converter
+	lazy         []zapcore.Field
+	withFields   []zapcore.Field
+	deferred     bool
+	messageKey   string
+	messageFrom  string
+	messageAttrs bool
+	templates    *templateCacheT
+	groups       []groupT
+	zeroTime     zapcore.Clock
+	clock        zapcore.Clock
+	core         zapcore.Core
+	pool         *poolT
 }
 
 func NewFromCore(core zapcore.Core, options ...Option) *ZapHandler {
@@ -47,6 +152,59 @@ func New(logger interface{ Core() zapcore.Core }, options ...Option) *ZapHandler
 	return NewFromCore(logger.Core(), options...)
 }
 
+// lowerLevel maps lvl to the zap level of the nearest slog level below it,
+// DebugLevel for levels below slog.LevelDebug.
+func lowerLevel(lvl slog.Level) zapcore.Level {
+	for _, known := range []slog.Level{LevelFatal, LevelPanic, slog.LevelError, slog.LevelWarn, slog.LevelInfo} {
+		if lvl >= known {
+			zapLvl, _ := level(known)
+
+			return zapLvl
+		}
+	}
+
+	return zapcore.DebugLevel
+}
+
+// zapLevel maps lvl to a zap level, along with the hook to run after writing.
+// Levels between the known ones only map if a level hook takes them, and are
+// then written at the zap level below them unless the hook panics or exits.
+func (hand *ZapHandler) zapLevel(lvl slog.Level) (zapcore.Level, zapcore.CheckWriteHook, bool) {
+	zapLvl, found := level(lvl)
+	if !found {
+		zapLvl = lowerLevel(lvl)
+	}
+
+	for _, levelHook := range hand.levelHooks {
+		if lvl < levelHook.level {
+			continue
+		}
+
+		switch levelHook.hook {
+		case zapcore.WriteThenPanic:
+			zapLvl = zapcore.PanicLevel
+		case zapcore.WriteThenFatal:
+			zapLvl = zapcore.FatalLevel
+		}
+
+		return zapLvl, levelHook.hook, true
+	}
+
+	return zapLvl, nil, found
+}
+
+// Sync flushes the core.
+func (hand *ZapHandler) Sync() error {
+	if err := hand.core.Sync(); err != nil {
+		return fmt.Errorf("error syncing core: %w", err)
+	}
+
+	return nil
+}
+
+// usesCaller reports whether records are written with their caller.
+func (hand *ZapHandler) usesCaller() bool { return hand.AddSource || hand.sourceKey != "" }
+
 func (hand *ZapHandler) clone() ZapHandler {
 	return *hand
 }
@@ -60,14 +218,29 @@ func (hand *ZapHandler) clone() ZapHandler {
 // or the method does not take a context.
 // The context is passed so Enabled can use its values
 // to make a decision.
-func (hand *ZapHandler) Enabled(_ context.Context, l slog.Level) bool {
-	if v, ok := level(l); ok {
-		return hand.core.Enabled(v)
+func (hand *ZapHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	if hand.enabled(ctx, l) {
+		return true
 	}
 
+	hand.stats.dropLevel()
+
 	return false
 }
 
+// enabled is Enabled without counting dropped records.
+func (hand *ZapHandler) enabled(ctx context.Context, l slog.Level) bool {
+	if lvl, ok := levelFrom(ctx); ok {
+		_, _, mapped := hand.zapLevel(l)
+
+		return mapped && l >= lvl
+	}
+
+	v, hook, ok := hand.zapLevel(l)
+
+	return ok && (hook != nil || hand.core.Enabled(v))
+}
+
 // Handle handles the Record.
 // It will only be called when Enabled returns true.
 // The Context argument is as for Enabled.
@@ -86,64 +259,273 @@ func (hand *ZapHandler) Enabled(_ context.Context, l slog.Level) bool {
 //   - If a group has no Attrs (even if it has a non-empty key),
 //     ignore it.
 func (hand *ZapHandler) Handle(ctx context.Context, rec slog.Record) error {
+	return hand.handleError(hand.handle(ctx, rec, false))
+}
+
+// forceHandle is Handle for records which are to be written even though the
+// core is not enabled for their level.
+func (hand *ZapHandler) forceHandle(ctx context.Context, rec slog.Record) error {
+	return hand.handleError(hand.handle(ctx, rec, true))
+}
+
+func (hand *ZapHandler) handleError(err error) error {
+	if err != nil && hand.errorHandler != nil {
+		hand.errorHandler(err)
+
+		return nil
+	}
+
+	return err
+}
+
+func (hand *ZapHandler) handle(ctx context.Context, rec slog.Record, force bool) error {
 	if err := ctx.Err(); err != nil {
+		hand.stats.dropContext()
+
 		return fmt.Errorf("error from context: %w", err)
 	}
 
-	lvl := zapcore.Level(rec.Level)
-	if l, ok := level(rec.Level); ok {
-		lvl = l
+	if lvl, ok := levelFrom(ctx); ok {
+		if rec.Level < lvl {
+			hand.stats.dropLevel()
+
+			return nil
+		}
+
+		force = true
+	}
+
+	lvl, hook, mapped := hand.zapLevel(rec.Level)
+	if force && !mapped {
+		hand.stats.dropLevel()
+
+		return nil
+	}
+
+	// WithClock replaces the time of every record. Otherwise a zero time is
+	// filled from ZeroTimeClock if set, or left out by cores from NewCore.
+	switch {
+	case hand.clock != nil:
+		rec.Time = hand.clock.Now()
+	case rec.Time.IsZero() && hand.zeroTime != nil:
+		rec.Time = hand.zeroTime.Now()
+	}
+
+	// Walking the stack for CallerSkip is only worth it if the caller is used.
+	var caller zapcore.EntryCaller
+	if hand.usesCaller() {
+		caller = hand.pool.caller(hand.pool.skipCallers(rec.PC, hand.callerSkip), true)
+	}
+
+	if rec.Message == "" && hand.messageFrom != "" {
+		rec = hand.messageFromAttr(rec)
+	}
+
+	ent := zapcore.Entry{Level: lvl, Time: rec.Time, Message: rec.Message}
+	if hand.messageKey != "" {
+		ent.Message = ""
+	}
+
+	if hand.AddSource {
+		ent.Caller = caller
+	}
+
+	var chk checker
+
+	checked := chk.check(hand.core, ent, nil)
+	if checked == nil && force {
+		checked = hand.forceCheck(&chk, ent)
+	}
+
+	accepted := checked != nil
+	if !accepted {
+		hand.stats.dropLevel()
+	}
+
+	var (
+		conv     types.Converter
+		template string
+	)
+
+	// Messages are rendered only for records which get written, with the
+	// values the fields end up with.
+	if accepted || hook != nil {
+		conv = hand.recordConverter(rec)
+		rec, template = hand.message(conv, rec)
+
+		if hand.messageKey == "" {
+			ent.Message = rec.Message
+			if checked != nil {
+				checked.Entry.Message = rec.Message
+			}
+		}
+	}
+
+	var after *afterWrite
+	if accepted && len(hand.entryHooks)+len(hand.writeHooks) > 0 {
+		after = &afterWrite{hand: hand, rec: rec, hook: hook}
+		hook = after
+	}
+
+	if hook != nil {
+		checked = checked.After(ent, hook)
 	}
 
-	checked := hand.core.Check(zapcore.Entry{
-		Level:   lvl,
-		Time:    rec.Time,
-		Message: rec.Message,
-		Caller:  hand.pool.caller(rec.PC, hand.AddSource),
-	}, nil)
 	if checked == nil {
 		return nil
 	}
 
-	var errOut Error
-	checked.ErrorOutput = &errOut
+	errOut := chk.errs
+	if errOut == nil {
+		errOut = &Error{}
+	}
 
-	hand.write(rec, checked)
+	checked.ErrorOutput = errOut
+
+	hand.write(rec, checked, entryT{caller: caller, template: template, conv: conv})
+
+	if after != nil {
+		errOut.Add(after.err)
+	}
+
+	if accepted {
+		hand.stats.written(errOut)
+	}
 
 	return errOut.Err()
 }
 
-func (hand *ZapHandler) write(rec slog.Record, checked *zapcore.CheckedEntry) {
-	if len(hand.groups) == 0 {
-		hand.pool.withFields(func(field []zapcore.Field) {
-			rec.Attrs(func(attr slog.Attr) bool {
-				field = hand.appendAttr(field, attr)
+// forceCheck checks ent as if it was at the lowest level the core is enabled
+// for, and puts its level back afterwards. Forced records thus only go where
+// the least severe records of the core go, leaving out the cores of a tee with
+// higher levels, such as error sinks. Samplers still apply.
+func (hand *ZapHandler) forceCheck(chk *checker, ent zapcore.Entry) *zapcore.CheckedEntry {
+	lowest := zapcore.LevelOf(hand.core)
+	if lowest <= ent.Level || lowest >= zapcore.InvalidLevel {
+		return nil
+	}
+
+	at := ent
+	at.Level = lowest
+
+	checked := chk.check(hand.core, at, nil)
+	if checked != nil {
+		checked.Entry.Level = ent.Level
+	}
+
+	return checked
+}
+
+// entryT is what Handle worked out of a record for writing it.
+type entryT struct {
+	caller   zapcore.EntryCaller
+	template string
+	conv     types.Converter
+}
+
+func (hand *ZapHandler) write(rec slog.Record, checked *zapcore.CheckedEntry, ent entryT) {
+	hand.pool.withFields(func(fields []zapcore.Field) {
+		var start time.Time
+		if hand.stats != nil {
+			start = time.Now()
+		}
+
+		var kept, dropped int
+
+		conv := ent.conv
+
+		rec.Attrs(func(attr slog.Attr) bool {
+			if maxAttrs := hand.conv.Limits.MaxAttrs; maxAttrs > 0 && kept >= maxAttrs {
+				dropped++
 
 				return true
-			})
+			}
+
+			kept++
+			fields = conv.AppendFields(fields, attr)
 
-			checked.Write(field...)
+			return true
 		})
-	} else {
-		hand.pool.withAttrs(func(attrs []slog.Attr) {
-			rec.Attrs(func(attr slog.Attr) bool {
-				attrs = append(attrs, attr)
 
-				return true
+		if dropped > 0 {
+			fields = append(fields, types.FieldType{Type: zapcore.Int64Type, Integer: int64(dropped)}.Field(TruncatedKey))
+		}
+
+		hand.stats.converted(start, fields)
+
+		if hand.reflected != nil {
+			hand.reflected.record(fields, func() zapcore.EntryCaller {
+				return hand.pool.caller(hand.pool.skipCallers(rec.PC, hand.callerSkip), true)
 			})
+		}
 
-			grp := slog.Attr{
-				Key:   hand.groups[len(hand.groups)-1],
-				Value: slog.GroupValue(attrs...),
-			}
+		fields = hand.group(conv, fields)
+		if len(hand.lazy) > 0 {
+			fields = append(append(make([]zapcore.Field, 0, len(hand.lazy)+len(fields)), conv.Rebind(hand.lazy)...), fields...)
+		}
 
-			for i := len(hand.groups) - 1 - 1; i >= 0; i-- {
-				grp = slog.Group(hand.groups[i], grp)
-			}
+		if hand.messageKey != "" {
+			fields = append(fields, types.FieldType{Type: zapcore.StringType, String: rec.Message}.Field(hand.messageKey))
+		}
 
-			checked.Write(types.NewFieldType(grp.Value).Field(grp.Key))
-		})
+		// The source stays outside of the open groups, like slog.SourceKey.
+		if hand.sourceKey != "" && ent.caller.Defined {
+			fields = append(fields, types.FieldType{
+				Type:      zapcore.ObjectMarshalerType,
+				Interface: &types.Source{Function: ent.caller.Function, File: ent.caller.File, Line: ent.caller.Line},
+			}.Field(hand.sourceKey))
+		}
+
+		if ent.template != "" {
+			fields = append(fields, types.FieldType{Type: zapcore.StringType, String: ent.template}.Field(MessageTemplateKey))
+		}
+
+		checked.Write(fields...)
+	})
+}
+
+// recordConverter returns the Converter for the fields of rec, which keeps
+// what lazy values evaluate to if rec or the handler may hold any.
+func (hand *ZapHandler) recordConverter(rec slog.Record) types.Converter {
+	if hand.deferred || mayDefer(rec) {
+		return hand.conv.ForRecord()
+	}
+
+	return hand.conv
+}
+
+// mayDefer reports whether rec has attrs which may hold lazy values, for which
+// the fields are to be converted with a Converter for the record.
+func mayDefer(rec slog.Record) bool {
+	found := false
+
+	rec.Attrs(func(attr slog.Attr) bool {
+		kind := attr.Value.Kind()
+		found = kind == slog.KindLogValuer || kind == slog.KindGroup
+
+		return !found
+	})
+
+	return found
+}
+
+// group nests fields into the open groups, innermost first, rebinding the
+// fields of the groups to conv. Groups which end up without any field are
+// left out.
+func (hand *ZapHandler) group(conv types.Converter, fields []zapcore.Field) []zapcore.Field {
+	for i := len(hand.groups) - 1; i >= 0; i-- {
+		grp := hand.groups[i]
+
+		obj := make(types.Fields, 0, len(grp.fields)+len(fields))
+		obj = append(append(obj, conv.Rebind(grp.fields)...), fields...)
+
+		fields = fields[:0]
+		if len(obj) > 0 {
+			fields = append(fields, types.FieldType{Type: zapcore.ObjectMarshalerType, Interface: obj}.Field(grp.name))
+		}
 	}
+
+	return fields
 }
 
 // WithAttrs returns a new Handler whose attributes consist of
@@ -152,12 +534,47 @@ func (hand *ZapHandler) write(rec slog.Record, checked *zapcore.CheckedEntry) {
 func (hand *ZapHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	cloned := hand.clone()
 
+	// Attrs of an open group can not go to the core, as the group has to be
+	// left out if no attrs end up in it.
+	if last := len(cloned.groups) - 1; last >= 0 {
+		cloned.groups = slices.Clone(cloned.groups)
+
+		fields := cloned.groups[last].fields
+		cloned.groups[last].fields = hand.conv.AppendFields(slices.Clip(fields), attrs...)
+		hand.convertedWith(cloned.groups[last].fields[len(fields):])
+
+		if slices.ContainsFunc(cloned.groups[last].fields[len(fields):], types.IsDeferred) {
+			cloned.deferred = true
+		}
+
+		return &cloned
+	}
+
 	hand.pool.withFields(func(f []zapcore.Field) {
-		for _, attr := range attrs {
-			f = hand.appendAttr(f, attr)
+		f = hand.conv.AppendFields(f, attrs...)
+		hand.convertedWith(f)
+
+		// Cores do not give their fields back, so they are kept for messages.
+		if hand.rendersAttrs() {
+			cloned.withFields = append(slices.Clip(cloned.withFields), f...)
+		}
+
+		// Cores encode the fields of With right away, so lazy ones are added
+		// to each record instead, along with every field after them to keep
+		// the order.
+		eager := len(f)
+		if len(cloned.lazy) > 0 {
+			eager = 0
+		} else if i := slices.IndexFunc(f, types.IsLazy); i >= 0 {
+			eager = i
 		}
 
-		cloned.core = cloned.core.With(f)
+		if eager < len(f) {
+			cloned.lazy = append(slices.Clip(cloned.lazy), f[eager:]...)
+			cloned.deferred = true
+		}
+
+		cloned.core = cloned.core.With(f[:eager])
 	})
 
 	return &cloned
@@ -189,25 +606,7 @@ func (hand *ZapHandler) WithGroup(name string) slog.Handler {
 	}
 
 	cloned := hand.clone()
-	cloned.groups = append(cloned.groups, name)
+	cloned.groups = append(slices.Clip(cloned.groups), groupT{name: name})
 
 	return &cloned
 }
-
-func (hand *ZapHandler) appendAttr(fields []zapcore.Field, attr slog.Attr) []zapcore.Field {
-	// If an Attr's key and value are both the zero value, ignore the Attr.
-	if attr.Equal(slog.Attr{}) {
-		return fields
-	}
-
-	// If a group's key is empty, inline the group's Attrs.
-	if attr.Value.Kind() == slog.KindGroup && attr.Key == "" {
-		for _, a := range attr.Value.Group() {
-			fields = hand.appendAttr(fields, a)
-		}
-
-		return fields
-	}
-
-	return append(fields, types.NewFieldType(attr.Value).Field(attr.Key))
-}
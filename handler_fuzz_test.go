@@ -1,8 +1,18 @@
 package zaphandler_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"log/slog"
+	"math"
+	"math/big"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,6 +20,7 @@ import (
 	"go.mrchanchal.com/zaphandler/types"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 type DummyStringer string
@@ -444,5 +455,198 @@ func FuzzFields(f *testing.F) {
 			v := []error{errVal1, errVal2}
 			matchParallel(t, func(l *zap.Logger) { l.Info(msg, zap.Errors(key, v)) }, func(l *slog.Logger) { l.Info(msg, key, v) })
 		})
+		t.Run("NetipAddr", func(t *testing.T) {
+			v := netip.AddrFrom4([4]byte{uint8Val1, uint8Val2, uint8(uint16Val1), uint8(uint16Val2)})
+			matchParallel(t, func(l *zap.Logger) { l.Info(msg, zap.String(key, v.String())) }, func(l *slog.Logger) { l.Info(msg, key, v) })
+		})
+		t.Run("NetipPrefix", func(t *testing.T) {
+			v := netip.PrefixFrom(netip.AddrFrom16([16]byte{uint8Val1, uint8Val2}), int(uint8Val1%129))
+			matchParallel(t, func(l *zap.Logger) { l.Info(msg, zap.String(key, v.String())) }, func(l *slog.Logger) { l.Info(msg, key, v) })
+		})
+		t.Run("NetIP", func(t *testing.T) {
+			v := net.IP(bytesVal1)
+			matchParallel(t, func(l *zap.Logger) { l.Info(msg, zap.String(key, v.String())) }, func(l *slog.Logger) { l.Info(msg, key, v) })
+		})
+		t.Run("URL", func(t *testing.T) {
+			v := &url.URL{Scheme: "https", User: url.UserPassword(key, msg), Host: "example.com", Path: msg}
+			matchParallel(t, func(l *zap.Logger) { l.Info(msg, zap.String(key, v.Redacted())) }, func(l *slog.Logger) { l.Info(msg, key, v) })
+		})
+		t.Run("Month", func(t *testing.T) {
+			v := time.Month(uint8Val1%12 + 1)
+			matchParallel(t, func(l *zap.Logger) { l.Info(msg, zap.String(key, v.String())) }, func(l *slog.Logger) { l.Info(msg, key, v) })
+		})
+		t.Run("Weekday", func(t *testing.T) {
+			v := time.Weekday(uint8Val1 % 7)
+			matchParallel(t, func(l *zap.Logger) { l.Info(msg, zap.String(key, v.String())) }, func(l *slog.Logger) { l.Info(msg, key, v) })
+		})
+		t.Run("BigInt", func(t *testing.T) {
+			v := new(big.Int).Mul(big.NewInt(int64Val1), big.NewInt(int64Val2))
+			matchParallel(t, func(l *zap.Logger) { l.Info(msg, zap.String(key, v.String())) }, func(l *slog.Logger) { l.Info(msg, key, v) })
+		})
+		t.Run("BigFloat", func(t *testing.T) {
+			if math.IsNaN(float64Val1) {
+				t.Skip("big.Float has no NaN")
+			}
+
+			v := big.NewFloat(float64Val1)
+			matchParallel(t, func(l *zap.Logger) { l.Info(msg, zap.String(key, v.Text('g', -1))) }, func(l *slog.Logger) { l.Info(msg, key, v) })
+		})
+		t.Run("RawMessage", func(t *testing.T) {
+			v := json.RawMessage(bytesVal1)
+
+			expected := zap.String(key, string(v))
+			if json.Valid(v) {
+				expected = zap.Reflect(key, types.RawJSON(v))
+			}
+
+			matchParallel(t, func(l *zap.Logger) { l.Info(msg, expected) }, func(l *slog.Logger) { l.Info(msg, key, v) })
+		})
+		t.Run("RawJSON", func(t *testing.T) {
+			v := types.RawJSON(bytesVal1)
+
+			expected := zap.String(key, string(v))
+			if json.Valid(v) {
+				expected = zap.Reflect(key, v)
+			}
+
+			matchParallel(t, func(l *zap.Logger) { l.Info(msg, expected) }, func(l *slog.Logger) { l.Info(msg, key, v) })
+		})
+		t.Run("Header", func(t *testing.T) {
+			v := http.Header{key: {msg, key}}
+			matchParallel(t, func(l *zap.Logger) { l.Info(msg, zap.Object(key, types.Header(v))) }, func(l *slog.Logger) { l.Info(msg, key, v) })
+		})
+		t.Run("FileMode", func(t *testing.T) {
+			v := os.FileMode(uint32Val1)
+			matchParallel(t, func(l *zap.Logger) { l.Info(msg, zap.String(key, v.String())) }, func(l *slog.Logger) { l.Info(msg, key, v) })
+		})
+	})
+}
+
+func depth(val any) int {
+	grp, ok := val.(map[string]any)
+	if !ok {
+		return 0
+	}
+
+	ret := 0
+	for _, v := range grp {
+		ret = max(ret, depth(v))
+	}
+
+	return ret + 1
+}
+
+func FuzzLimits(f *testing.F) {
+	f.Add("", []byte{}, uint8(0), uint8(0), uint8(0), uint8(0), uint8(0))
+	f.Add("test log message", []byte{1, 2, 3, 4}, uint8(4), uint8(2), uint8(1), uint8(2), uint8(3))
+	f.Add("ünïcödé", []byte{1}, uint8(3), uint8(1), uint8(2), uint8(1), uint8(5))
+
+	f.Fuzz(func(t *testing.T, str string, arr []byte, maxString, maxArray, maxDepth, maxAttrs, nesting uint8) {
+		t.Parallel()
+
+		core, obs := observer.New(zapcore.DebugLevel)
+		logger := slog.New(zaphandler.NewFromCore(core,
+			zaphandler.MaxStringLength(int(maxString)),
+			zaphandler.MaxArrayLength(int(maxArray)),
+			zaphandler.MaxGroupDepth(int(maxDepth)),
+			zaphandler.MaxAttrs(int(maxAttrs)),
+		))
+
+		nested := slog.StringValue(str)
+		for i := 0; i < int(nesting%16); i++ {
+			nested = slog.GroupValue(slog.Any("n", nested))
+		}
+
+		ints := make([]int, len(arr))
+		for i, b := range arr {
+			ints[i] = int(b)
+		}
+
+		logger.Info("limits", "s", str, "a", ints, "n", nested,
+			"b", arr, "bs", types.ByteString(str), "e", errors.New(str), "st", DummyStringer(str),
+			"r", struct{ S string }{S: str}, types.LazyField(func() zapcore.Field { return zap.String("lz", str) }),
+			"ne", (*url.Error)(nil), "ns", (*DummyStringer)(nil))
+
+		ctx := obs.TakeAll()[0].ContextMap()
+
+		attrs := len(ctx)
+		if dropped, ok := ctx[zaphandler.TruncatedKey].(int64); ok {
+			attrs--
+
+			if attrs+int(dropped) != 11 {
+				t.Errorf("expected 11 attrs in total, got %d and %d dropped", attrs, dropped)
+			}
+		}
+
+		if maxAttrs > 0 && attrs > int(maxAttrs) {
+			t.Errorf("expected at most %d attrs, got %d", maxAttrs, attrs)
+		}
+
+		cut := func(key, full, got string) {
+			switch {
+			case maxString == 0 || len(full) <= int(maxString):
+				if got != full {
+					t.Errorf("expected %s %q, got %q", key, full, got)
+				}
+			case len(got) > int(maxString)+len(types.Truncated) || !strings.HasSuffix(got, types.Truncated):
+				t.Errorf("expected %s cut to %d, got %q", key, maxString, got)
+			}
+		}
+
+		for _, key := range []string{"s", "bs", "e", "st", "lz"} {
+			if got, ok := ctx[key].(string); ok {
+				cut(key, str, got)
+			}
+		}
+
+		for _, key := range []string{"ne", "ns"} {
+			if got, ok := ctx[key]; ok && got != "<nil>" {
+				t.Errorf("expected %s to be <nil>, got %v", key, got)
+			}
+		}
+
+		if got, ok := ctx["b"].([]byte); ok && maxString > 0 && len(got) > int(maxString) {
+			t.Errorf("expected at most %d bytes, got %d", maxString, len(got))
+		}
+
+		if got, ok := ctx["r"]; ok {
+			full := encodeJSON(t, struct{ S string }{S: str})
+			encoded := encodeJSON(t, got)
+
+			if len(full) > int(maxString) && maxString > 0 {
+				var text string
+				if err := json.Unmarshal(encoded, &text); err != nil {
+					t.Fatalf("expected a cut reflected value to be a string, got %s", encoded)
+				}
+
+				cut("r", string(full), text)
+			} else if !bytes.Equal(encoded, full) {
+				t.Errorf("expected reflected %s, got %s", full, encoded)
+			}
+		}
+
+		if got, ok := ctx["a"].([]any); ok && maxArray > 0 && len(got) > int(maxArray)+1 {
+			t.Errorf("expected at most %d elements, got %d", maxArray, len(got))
+		}
+
+		if got, ok := ctx["n"]; ok && maxDepth > 0 && depth(got) > int(maxDepth) {
+			t.Errorf("expected depth at most %d, got %d", maxDepth, depth(got))
+		}
 	})
 }
+
+// encodeJSON encodes val the way zap encodes reflected values.
+func encodeJSON(tb testing.TB, val any) []byte {
+	tb.Helper()
+
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(val); err != nil {
+		tb.Fatal(err)
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
@@ -0,0 +1,103 @@
+package zaphandler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"testing/slogtest"
+	"time"
+
+	"go.mrchanchal.com/zaphandler"
+	"go.uber.org/zap/zapcore"
+)
+
+func JSONCore(buf *bytes.Buffer) zapcore.Core {
+	return zaphandler.NewCore(zapcore.NewJSONEncoder, zapcore.EncoderConfig{
+		TimeKey:        slog.TimeKey,
+		LevelKey:       slog.LevelKey,
+		MessageKey:     slog.MessageKey,
+		CallerKey:      slog.SourceKey,
+		EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+		EncodeLevel:    zapcore.CapitalLevelEncoder,
+		EncodeDuration: zapcore.NanosDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}, zapcore.AddSync(buf), zapcore.DebugLevel)
+}
+
+func ParseJSON(tb testing.TB, data []byte) []map[string]any {
+	tb.Helper()
+
+	var ret []map[string]any
+
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+
+		var m map[string]any
+		if err := json.Unmarshal(line, &m); err != nil {
+			tb.Fatalf("invalid json %q: %v", line, err)
+		}
+
+		ret = append(ret, m)
+	}
+
+	return ret
+}
+
+func TestSlogTest(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	if err := slogtest.TestHandler(zaphandler.NewFromCore(JSONCore(&buf), zaphandler.AddSource()), func() []map[string]any {
+		return ParseJSON(t, buf.Bytes())
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+type FixedClock time.Time
+
+func (c FixedClock) Now() time.Time                         { return time.Time(c) }
+func (c FixedClock) NewTicker(d time.Duration) *time.Ticker { return time.NewTicker(d) }
+
+func TestZeroTime(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, time.August, 8, 10, 0, 0, 0, time.UTC)
+	rec := slog.NewRecord(time.Time{}, slog.LevelInfo, "zero", 0)
+
+	for _, test := range []struct {
+		name     string
+		options  []zaphandler.Option
+		expected any
+	}{
+		{name: "Omitted"},
+		{name: "Clock", options: []zaphandler.Option{zaphandler.ZeroTimeClock(FixedClock(now))}, expected: now.Format(time.RFC3339Nano)},
+		{name: "WithClock", options: []zaphandler.Option{zaphandler.WithClock(FixedClock(now))}, expected: now.Format(time.RFC3339Nano)},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			if err := zaphandler.NewFromCore(JSONCore(&buf), test.options...).Handle(context.Background(), rec); err != nil {
+				t.Fatal(err)
+			}
+
+			got := ParseJSON(t, buf.Bytes())
+			if len(got) != 1 {
+				t.Fatalf("expected 1 record, got %d", len(got))
+			}
+
+			if got[0][slog.TimeKey] != test.expected {
+				t.Errorf("expected time %v, got %v", test.expected, got[0][slog.TimeKey])
+			}
+		})
+	}
+}
@@ -1,16 +1,25 @@
 package zaphandler_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
 	"log/slog"
+	"net/netip"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"go.mrchanchal.com/zaphandler"
+	"go.mrchanchal.com/zaphandler/types"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
@@ -30,6 +39,21 @@ func MatchEntry(tb testing.TB, expected, got []observer.LoggedEntry) {
 	}
 }
 
+func MatchContext(tb testing.TB, expected, got []observer.LoggedEntry) {
+	tb.Helper()
+
+	if len(expected) != len(got) {
+		tb.Fatalf("length of expected(%d) is not matching with got(%d)", len(expected), len(got))
+	}
+
+	for i := range expected {
+		if !reflect.DeepEqual(expected[i].Entry, got[i].Entry) ||
+			!reflect.DeepEqual(expected[i].ContextMap(), got[i].ContextMap()) {
+			tb.Errorf("mismatched entry\nExpected: %+v\nGot:      %+v", expected[i], got[i])
+		}
+	}
+}
+
 func Take(o *observer.ObservedLogs) []observer.LoggedEntry {
 	ret := o.TakeAll()
 	for i := range ret {
@@ -173,6 +197,34 @@ func BenchmarkZapHandlerWithCaller(b *testing.B) {
 	}
 }
 
+func BenchmarkZapHandlerWithCallerNoCache(b *testing.B) {
+	for _, zapF := range BenchData() {
+		zapF := zapF
+
+		b.Run(zapF.Name, func(b *testing.B) {
+			zapL, err := zapF.F()
+			if err != nil {
+				b.Error(err)
+			}
+
+			defer func() {
+				if err := HandleNullSyncErr(zapL.Sync()); err != nil {
+					b.Error(err)
+				}
+			}()
+
+			logger := slog.New(zaphandler.New(zapL, zaphandler.AddSource(), zaphandler.CallerCacheSize(0)))
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				logger.Info("sample log message", "field1", "value1", "field2", 33, "field3", []int{32, 33})
+			}
+
+			b.StopTimer()
+		})
+	}
+}
+
 func BenchmarkZap(b *testing.B) {
 	for _, zapF := range BenchData() {
 		zapF := zapF
@@ -217,10 +269,1191 @@ func TestZapHandlerGroup(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.TODO()
+	logger, _, obs := ObsLogger(zap.DebugLevel)
 
-	Match(t, zap.DebugLevel, func(l *slog.Logger, _ *zap.Logger) {
-		l.WithGroup("s").LogAttrs(ctx, slog.LevelInfo, "", slog.Int("a", 1), slog.Int("b", 2))
-	}, func(l *slog.Logger, _ *zap.Logger) {
-		l.LogAttrs(ctx, slog.LevelInfo, "", slog.Group("s", slog.Int("a", 1), slog.Int("b", 2)))
+	logger.WithGroup("s").LogAttrs(ctx, slog.LevelInfo, "", slog.Int("a", 1), slog.Int("b", 2))
+	expected := Take(obs)
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "", slog.Group("s", slog.Int("a", 1), slog.Int("b", 2)))
+	MatchContext(t, expected, Take(obs))
+}
+
+func TestZapHandlerGroupWithAttrs(t *testing.T) {
+	t.Parallel()
+
+	logger, _, obs := ObsLogger(zap.DebugLevel)
+
+	logger.WithGroup("s").With("a", 1).WithGroup("t").Info("", "b", 2)
+	logger.WithGroup("s").With("a", 1).WithGroup("t").Info("")
+	logger.WithGroup("s").WithGroup("t").Info("")
+	got := Take(obs)
+
+	logger.Info("", slog.Group("s", slog.Int("a", 1), slog.Group("t", slog.Int("b", 2))))
+	logger.Info("", slog.Group("s", slog.Int("a", 1)))
+	logger.Info("")
+	MatchContext(t, Take(obs), got)
+}
+
+type resolveValuer struct{ val string }
+
+func (v resolveValuer) LogValue() slog.Value { return slog.StringValue(v.val) }
+
+func TestZapHandlerNestedGroupRules(t *testing.T) {
+	t.Parallel()
+
+	logger, _, obs := ObsLogger(zap.DebugLevel)
+
+	logger.Info("test", slog.Group("g",
+		slog.Attr{},
+		slog.Group("empty"),
+		slog.Group("nested-empty", slog.Group("empty"), slog.Attr{}),
+		slog.Group("", slog.Int("inlined", 1)),
+		slog.Any("resolved", resolveValuer{val: "value"}),
+		slog.Group("h", slog.Int("a", 1)),
+	))
+
+	entries := obs.TakeAll()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+
+	expected := map[string]any{
+		"g": map[string]any{
+			"inlined":  int64(1),
+			"resolved": "value",
+			"h":        map[string]any{"a": int64(1)},
+		},
+	}
+
+	if got := entries[0].ContextMap(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("mismatched context\nExpected: %+v\nGot:      %+v", expected, got)
+	}
+}
+
+type countValuer struct{ calls *atomic.Int32 }
+
+func (v countValuer) LogValue() slog.Value {
+	v.calls.Add(1)
+
+	return slog.IntValue(1)
+}
+
+func TestZapHandlerResolveOnce(t *testing.T) {
+	t.Parallel()
+
+	var (
+		buf   bytes.Buffer
+		calls atomic.Int32
+	)
+
+	logger := slog.New(zaphandler.NewFromCore(JSONCore(&buf)))
+	logger.Info("test", slog.Group("g", slog.Group("h", "a", countValuer{calls: &calls})))
+
+	if calls.Load() != 1 {
+		t.Errorf("expected a single LogValue call, got %d", calls.Load())
+	}
+
+	expected := map[string]any{"g": map[string]any{"h": map[string]any{"a": float64(1)}}}
+	if got := ParseJSON(t, buf.Bytes()); len(got) != 1 || !reflect.DeepEqual(expected["g"], got[0]["g"]) {
+		t.Errorf("mismatched record %v", got)
+	}
+}
+
+func TestCallerCache(t *testing.T) {
+	t.Parallel()
+
+	core, obs := observer.New(zap.DebugLevel)
+	logger := slog.New(zaphandler.NewFromCore(core, zaphandler.AddSource(), zaphandler.CallerCacheSize(1)))
+
+	var lines []int
+
+	for i := 0; i < 2; i++ {
+		logger.Info("first")
+		logger.Info("second")
+	}
+
+	for _, entry := range obs.TakeAll() {
+		lines = append(lines, entry.Caller.Line)
+	}
+
+	if len(lines) != 4 || lines[0] == lines[1] || lines[0] != lines[2] || lines[1] != lines[3] {
+		t.Errorf("mismatched caller lines: %v", lines)
+	}
+}
+
+func TestCallerFormat(t *testing.T) {
+	t.Parallel()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name           string
+		options        []zaphandler.Option
+		file, function string
+	}{
+		{
+			name:     "Default",
+			file:     wd + "/handler_test.go",
+			function: "go.mrchanchal.com/zaphandler_test.TestCallerFormat.func1",
+		},
+		{
+			name:     "TrimPrefix",
+			options:  []zaphandler.Option{zaphandler.TrimCallerPrefix("/not/matching", wd)},
+			file:     "handler_test.go",
+			function: "go.mrchanchal.com/zaphandler_test.TestCallerFormat.func1",
+		},
+		{
+			name:     "ModuleRelative",
+			options:  []zaphandler.Option{zaphandler.ModuleRelativeCaller(), zaphandler.ShortCallerFunction()},
+			file:     "go.mrchanchal.com/zaphandler/handler_test.go",
+			function: "zaphandler_test.TestCallerFormat.func1",
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			core, obs := observer.New(zap.DebugLevel)
+			slog.New(zaphandler.NewFromCore(core, append(test.options, zaphandler.AddSource())...)).Info("test")
+
+			caller := obs.TakeAll()[0].Caller
+			if caller.File != test.file || caller.Function != test.function {
+				t.Errorf("mismatched caller %s %s", caller.File, caller.Function)
+			}
+		})
+	}
+}
+
+//go:noinline
+func facadeInfo(logger *slog.Logger, msg string) { logger.Info(msg) }
+
+//go:noinline
+func facadeSkip(logger *slog.Logger, msg string) {
+	zaphandler.LogSkip(context.Background(), logger, 1, slog.LevelInfo, msg)
+}
+
+func TestCallerSkip(t *testing.T) {
+	t.Parallel()
+
+	core, obs := observer.New(zap.DebugLevel)
+	skipped := slog.New(zaphandler.NewFromCore(core, zaphandler.AddSource(), zaphandler.CallerSkip(1)))
+	direct := slog.New(zaphandler.NewFromCore(core, zaphandler.AddSource()))
+
+	facadeInfo(skipped, "skipped")
+	facadeSkip(direct, "skip")
+	direct.Info("direct")
+
+	entries := obs.TakeAll()
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+
+	want := entries[2].Caller
+	for _, entry := range entries[:2] {
+		if entry.Caller.Function != want.Function || entry.Caller.File != want.File {
+			t.Errorf("%s: expected caller %s, got %s", entry.Message, want.Function, entry.Caller.Function)
+		}
+
+		if entry.Caller.Line >= want.Line || entry.Caller.Line < want.Line-2 {
+			t.Errorf("%s: unexpected caller line %d", entry.Message, entry.Caller.Line)
+		}
+	}
+}
+
+// stackDepth is more frames than the handler walks at once.
+const stackDepth = 100
+
+//go:noinline
+func deepFacade(logger *slog.Logger, depth int) {
+	if depth > 0 {
+		deepFacade(logger, depth-1)
+
+		return
+	}
+
+	facadeInfo(logger, "deep")
+}
+
+func TestCallerSkipDeepStack(t *testing.T) {
+	t.Parallel()
+
+	core, obs := observer.New(zap.DebugLevel)
+	deepFacade(slog.New(zaphandler.NewFromCore(core, zaphandler.AddSource(), zaphandler.CallerSkip(stackDepth))), 2*stackDepth)
+
+	entries := obs.TakeAll()
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].Caller.Function, ".deepFacade") {
+		t.Errorf("expected deepFacade as the caller, got %+v", entries)
+	}
+}
+
+type countHook struct{ count *int }
+
+func (h countHook) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) { *h.count++ }
+
+func TestLevelHook(t *testing.T) {
+	t.Parallel()
+
+	core, obs := observer.New(zap.InfoLevel)
+	logger := slog.New(zaphandler.NewFromCore(core, zaphandler.PanicLevel(zaphandler.LevelPanic)))
+
+	func() {
+		defer func() {
+			if r := recover(); r != "panic message" {
+				t.Errorf("expected panic, got %v", r)
+			}
+		}()
+
+		zaphandler.Panic(logger, "panic message", "a", 1)
+	}()
+
+	if entries := obs.TakeAll(); len(entries) != 1 || entries[0].Level != zapcore.PanicLevel {
+		t.Errorf("expected a single panic entry, got %+v", entries)
+	}
+
+	var count int
+
+	core, obs = observer.New(zap.ErrorLevel)
+	logger = slog.New(zaphandler.NewFromCore(core, zaphandler.LevelHook(slog.LevelWarn, countHook{count: &count})))
+
+	logger.Info("ignored")
+	logger.Warn("hooked")
+	logger.Error("hooked")
+
+	if entries := obs.TakeAll(); count != 2 || len(entries) != 1 {
+		t.Errorf("expected 2 hooked records and 1 entry, got %d and %d", count, len(entries))
+	}
+
+	// Levels between the known ones go to the zap level below them.
+	core, obs = observer.New(zap.DebugLevel)
+	logger = slog.New(zaphandler.NewFromCore(core, zaphandler.LevelHook(slog.LevelWarn, countHook{count: &count})))
+
+	logger.Log(context.Background(), slog.LevelInfo+1, "dropped")
+	logger.Log(context.Background(), slog.LevelWarn+1, "warn")
+	logger.Log(context.Background(), slog.LevelError+2, "error")
+
+	entries := obs.TakeAll()
+	if len(entries) != 2 || entries[0].Level != zapcore.WarnLevel || entries[1].Level != zapcore.ErrorLevel {
+		t.Errorf("expected a warn and an error entry, got %+v", entries)
+	}
+}
+
+func TestPanicWithoutLevel(t *testing.T) {
+	t.Parallel()
+
+	core, obs := observer.New(zap.InfoLevel)
+	logger := slog.New(zaphandler.NewFromCore(core))
+
+	func() {
+		defer func() {
+			if r := recover(); r != "panic message" {
+				t.Errorf("expected panic, got %v", r)
+			}
+		}()
+
+		zaphandler.Panic(logger, "panic message")
+	}()
+
+	if entries := obs.TakeAll(); len(entries) != 1 || entries[0].Level != zapcore.PanicLevel {
+		t.Errorf("expected a single panic entry, got %+v", entries)
+	}
+}
+
+func TestFatal(t *testing.T) {
+	t.Parallel()
+
+	if os.Getenv("ZAPHANDLER_TEST_FATAL") == "1" {
+		core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), os.Stdout, zap.InfoLevel)
+		zaphandler.Fatal(slog.New(zaphandler.NewFromCore(core)), "fatal message", "a", 1)
+
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatal$") //nolint:gosec
+	cmd.Env = append(os.Environ(), "ZAPHANDLER_TEST_FATAL=1")
+
+	out, err := cmd.Output()
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("expected exit status 1, got %v", err)
+	}
+
+	entries := ParseJSON(t, out)
+	if len(entries) != 1 || entries[0]["msg"] != "fatal message" || entries[0]["level"] != "fatal" {
+		t.Errorf("expected the fatal record, got %s", out)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	t.Parallel()
+
+	var (
+		count   int
+		levels  = map[zapcore.Level]int{}
+		errHook = errors.New("hook error") //nolint:goerr113
+	)
+
+	core, _ := observer.New(zap.InfoLevel)
+	logger := slog.New(zaphandler.NewFromCore(core,
+		zaphandler.WriteHooks(countHook{count: &count}),
+		zaphandler.Hooks(func(ent zapcore.Entry, rec slog.Record) error {
+			levels[ent.Level]++
+
+			if rec.Message == "fail" {
+				return errHook
+			}
+
+			return nil
+		}),
+	))
+
+	logger.Debug("ignored")
+	logger.Info("info")
+	logger.Warn("warn")
+	logger.Warn("warn")
+
+	if count != 3 || levels[zapcore.InfoLevel] != 1 || levels[zapcore.WarnLevel] != 2 || levels[zapcore.DebugLevel] != 0 {
+		t.Errorf("unexpected hook calls %d %v", count, levels)
+	}
+
+	rec := slog.NewRecord(time.Now(), slog.LevelError, "fail", 0)
+	if err := logger.Handler().Handle(context.Background(), rec); !errors.Is(err, errHook) {
+		t.Errorf("expected hook error, got %v", err)
+	}
+}
+
+var errSink = errors.New("sink error") //nolint:goerr113
+
+type failingSink struct{}
+
+func (failingSink) Write([]byte) (int, error) { return 0, errSink }
+func (failingSink) Sync() error               { return nil }
+
+func TestWriteError(t *testing.T) {
+	t.Parallel()
+
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), failingSink{}, zap.InfoLevel)
+	rec := slog.NewRecord(time.Now(), slog.LevelInfo, "test", 0)
+
+	err := zaphandler.NewFromCore(core).Handle(context.Background(), rec)
+	if !errors.Is(err, zaphandler.ErrWrite) {
+		t.Fatalf("expected write error, got %v", err)
+	}
+
+	var writeErr *zaphandler.WriteError
+	if !errors.As(err, &writeErr) || writeErr.Message != errSink.Error() || writeErr.Time == "" {
+		t.Errorf("unexpected write error %#v", writeErr)
+	}
+
+	if !errors.Is(err, errSink) {
+		t.Errorf("expected the sink error, got %v", err)
+	}
+
+	observed, _ := observer.New(zap.InfoLevel)
+
+	err = zaphandler.NewFromCore(zapcore.NewTee(observed, core)).Handle(context.Background(), rec)
+	if !errors.Is(err, errSink) {
+		t.Errorf("expected the sink error from a tee, got %v", err)
+	}
+
+	// Samplers can not be told apart from tees, so only the text is left.
+	sampled := zapcore.NewSamplerWithOptions(core, time.Second, 10, 0)
+
+	err = zaphandler.NewFromCore(sampled).Handle(context.Background(), rec)
+	if !errors.As(err, &writeErr) || errors.Is(err, errSink) || !strings.Contains(writeErr.Message, errSink.Error()) {
+		t.Errorf("expected the text of the sink error, got %#v", err)
+	}
+
+	var handled []error
+
+	hand := zaphandler.NewFromCore(core, zaphandler.ErrorHandler(func(err error) { handled = append(handled, err) }))
+	if err := hand.Handle(context.Background(), rec); err != nil {
+		t.Errorf("expected error to be handled, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := hand.Handle(ctx, rec); err != nil {
+		t.Errorf("expected error to be handled, got %v", err)
+	}
+
+	if len(handled) != 2 || !errors.Is(handled[0], zaphandler.ErrWrite) || !errors.Is(handled[1], context.Canceled) {
+		t.Errorf("unexpected handled errors %v", handled)
+	}
+}
+
+// TestZapCoreLayout pins what the collection of write errors relies on: tees
+// being slices of their cores, and the cores of zapcore.NewCore adding
+// themselves when enabled.
+func TestZapCoreLayout(t *testing.T) {
+	t.Parallel()
+
+	first, _ := observer.New(zap.InfoLevel)
+	second := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), failingSink{}, zap.WarnLevel)
+
+	tee := reflect.ValueOf(zapcore.NewTee(first, second))
+	if tee.Kind() != reflect.Slice || tee.Len() != 2 ||
+		tee.Index(0).Interface() != first || tee.Index(1).Interface() != second {
+		t.Fatalf("expected tees to be slices of their cores, got %v", tee.Type())
+	}
+
+	if reflect.TypeOf(second) != reflect.TypeOf(zapcore.NewCore(nil, nil, nil)) {
+		t.Fatalf("expected cores of NewCore to share their type, got %T", second)
+	}
+
+	for lvl := zapcore.DebugLevel; lvl <= zapcore.FatalLevel; lvl++ {
+		checked := second.Check(zapcore.Entry{Level: lvl}, nil)
+		if (checked != nil) != second.Enabled(lvl) {
+			t.Errorf("expected cores of NewCore to be checked as enabled at %v", lvl)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	failing := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), failingSink{}, zap.InfoLevel)
+	core, _ := observer.New(zap.InfoLevel)
+	hand := zaphandler.NewFromCore(zapcore.NewTee(core, failing), zaphandler.CollectStats())
+	logger := slog.New(hand)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	logger.Debug("dropped")
+	logger.InfoContext(ctx, "dropped")
+	logger.With("a", 1, "w", reflected{A: 1}).WithGroup("g").With("gw", reflected{A: 2}).
+		Info("handled", "reflect", struct{ A int }{A: 1}, slog.Group("h", "nested", reflected{A: 3}), "b", 2,
+			"raw", types.RawJSON(`{}`))
+
+	stats := hand.Stats()
+	expected := zaphandler.Stats{
+		Handled:          1,
+		DroppedLevel:     1,
+		DroppedContext:   1,
+		WriteErrors:      1,
+		ReflectFallbacks: 4,
+		ConversionTime:   stats.ConversionTime,
+	}
+
+	if stats != expected || stats.ConversionTime < 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	hand.PublishExpvar("zaphandler_test_stats")
+
+	if got := expvar.Get("zaphandler_test_stats").String(); !strings.Contains(got, `"Handled":1`) {
+		t.Errorf("unexpected expvar %s", got)
+	}
+
+	if (zaphandler.NewFromCore(core).Stats() != zaphandler.Stats{}) {
+		t.Error("expected no stats without CollectStats")
+	}
+}
+
+type reflected struct{ A int }
+
+func TestReflectReport(t *testing.T) {
+	t.Parallel()
+
+	core, _ := observer.New(zap.InfoLevel)
+	hand := zaphandler.NewFromCore(core, zaphandler.ReportReflect())
+	logger := slog.New(hand)
+
+	logger.Info("test", "a", reflected{A: 1}, "b", 1, "raw", types.RawJSON(`[]`), "message", json.RawMessage(`{}`))
+	logger.Info("test", slog.Group("g", "a", reflected{A: 2}, "c", map[string]int{}))
+	logger.WithGroup("h").Info("test", "a", reflected{A: 3})
+	logger.With("w", reflected{A: 4}).Info("test")
+
+	report := hand.ReflectReport()
+	if len(report) != 2 ||
+		report[0].Type != "zaphandler_test.reflected" || report[0].Count != 4 ||
+		report[1].Type != "map[string]int" || report[1].Count != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	if !strings.HasSuffix(report[0].Caller.File, "handler_test.go") {
+		t.Errorf("unexpected first call site %v", report[0].Caller)
+	}
+
+	var buf strings.Builder
+	if err := hand.WriteReflectReport(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 2 ||
+		!strings.HasPrefix(lines[0], "4\tzaphandler_test.reflected\t") {
+		t.Errorf("unexpected report output %q", buf.String())
+	}
+}
+
+type (
+	registeredID [2]byte
+	handlerID    [2]byte
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	types.Register(func(id registeredID) types.FieldType {
+		return types.FieldType{Type: zapcore.StringType, String: fmt.Sprintf("%x", id[:])}
 	})
+
+	reg := types.NewRegistry()
+	types.Add(reg, func(id handlerID) types.FieldType {
+		return types.FieldType{Type: zapcore.Int64Type, Integer: int64(id[0])<<8 | int64(id[1])}
+	})
+
+	core, obs := observer.New(zap.InfoLevel)
+	logger := slog.New(zaphandler.NewFromCore(core, zaphandler.WithRegistry(reg)))
+
+	logger.Info("test", "a", registeredID{1, 2}, slog.Group("g", "b", handlerID{1, 2}, "c", &handlerID{0, 1}))
+	slog.New(zaphandler.NewFromCore(core)).Info("test", "b", handlerID{1, 2})
+
+	entries := obs.TakeAll()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	expected := map[string]any{"a": "0102", "g": map[string]any{"b": int64(258), "c": int64(1)}}
+	if got := entries[0].ContextMap(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("mismatched context\nExpected: %+v\nGot:      %+v", expected, got)
+	}
+
+	if field := entries[1].Context[0]; field.Type != zapcore.ReflectType {
+		t.Errorf("expected handler registry to stay with its handler, got %+v", field)
+	}
+}
+
+func TestMessageOptions(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		name    string
+		options []zaphandler.Option
+		log     func(*slog.Logger)
+		message string
+		context map[string]any
+	}{
+		{
+			name:    "Key",
+			options: []zaphandler.Option{zaphandler.MessageKey("message")},
+			log:     func(l *slog.Logger) { l.WithGroup("g").Info("test", "a", 1) },
+			context: map[string]any{"message": "test", "g": map[string]any{"a": int64(1)}},
+		},
+		{
+			name:    "WithAttrs",
+			options: []zaphandler.Option{zaphandler.MessageWithAttrs()},
+			log:     func(l *slog.Logger) { l.Info("test", "a", 1, slog.Group("g", "b", "c d")) },
+			message: `test a=1 g.b="c d"`,
+			context: map[string]any{"a": int64(1), "g": map[string]any{"b": "c d"}},
+		},
+		{
+			name:    "WithAttrsOfHandler",
+			options: []zaphandler.Option{zaphandler.MessageWithAttrs()},
+			log: func(l *slog.Logger) {
+				l.With("w", 1).WithGroup("g").With("gw", types.Int32(2)).Info("test", "a", types.Int32(3), "t", time.Unix(0, 0).UTC())
+			},
+			message: `test w=1 g.gw=2 g.a=3 g.t=1970-01-01T00:00:00Z`,
+			context: map[string]any{"w": int64(1), "g": map[string]any{"gw": int32(2), "a": int32(3), "t": time.Unix(0, 0).UTC()}},
+		},
+		{
+			name:    "From",
+			options: []zaphandler.Option{zaphandler.MessageFrom("event")},
+			log:     func(l *slog.Logger) { l.Info("", "event", "from attr", "a", 1) },
+			message: "from attr",
+			context: map[string]any{"a": int64(1)},
+		},
+		{
+			name:    "FromKeepsMessage",
+			options: []zaphandler.Option{zaphandler.MessageFrom("event")},
+			log:     func(l *slog.Logger) { l.Info("test", "event", "attr") },
+			message: "test",
+			context: map[string]any{"event": "attr"},
+		},
+	} {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			core, obs := observer.New(zap.DebugLevel)
+			test.log(slog.New(zaphandler.NewFromCore(core, test.options...)))
+
+			entry := obs.TakeAll()[0]
+			if entry.Message != test.message {
+				t.Errorf("expected message %q, got %q", test.message, entry.Message)
+			}
+
+			if got := entry.ContextMap(); !reflect.DeepEqual(test.context, got) {
+				t.Errorf("mismatched context\nExpected: %+v\nGot:      %+v", test.context, got)
+			}
+		})
+	}
+}
+
+func TestMessageWithAttrsAfterCheck(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	value := types.Lazy(func() slog.Value {
+		calls.Add(1)
+
+		return slog.StringValue("lazy")
+	})
+
+	core, obs := observer.New(zap.InfoLevel)
+	logger := slog.New(zaphandler.NewFromCore(zapcore.NewTee(core, core), zaphandler.MessageWithAttrs()))
+
+	logger.Debug("dropped", "l", value)
+
+	if calls.Load() != 0 {
+		t.Fatalf("expected no evaluation for dropped records, got %d", calls.Load())
+	}
+
+	logger.Info("test", "l", value)
+
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 evaluation, got %d", calls.Load())
+	}
+
+	for _, entry := range obs.TakeAll() {
+		if entry.Message != "test l=lazy" {
+			t.Errorf("unexpected message %q", entry.Message)
+		}
+	}
+}
+
+func TestMessageTemplate(t *testing.T) {
+	t.Parallel()
+
+	core, obs := observer.New(zap.DebugLevel)
+	logger := slog.New(zaphandler.NewFromCore(core, zaphandler.MessageTemplate()))
+	custom := slog.New(zaphandler.NewFromCore(core, zaphandler.TemplateDelims("<%", "%>")))
+
+	for i := 0; i < 2; i++ {
+		logger.Info("user {user} logged in from {ip}", "user", "gopher", "ip", netip.MustParseAddr("127.0.0.1"))
+	}
+
+	logger.Info("no {missing} key, {{escaped} and {unclosed", "a", 1)
+	logger.Info("plain {{message}")
+	logger.Info("{{user}} is {user}", "user", "bob")
+	custom.Info("user <%user%> {user}", "user", "gopher")
+
+	expected := []struct {
+		message  string
+		template string
+	}{
+		{message: "user gopher logged in from 127.0.0.1", template: "user {user} logged in from {ip}"},
+		{message: "user gopher logged in from 127.0.0.1", template: "user {user} logged in from {ip}"},
+		{message: "no {missing} key, {escaped} and {unclosed", template: "no {missing} key, {{escaped} and {unclosed"},
+		{message: "plain {message}"},
+		{message: "{user} is bob", template: "{{user}} is {user}"},
+		{message: "user gopher {user}", template: "user <%user%> {user}"},
+	}
+
+	entries := obs.TakeAll()
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %d", len(expected), len(entries))
+	}
+
+	for i, entry := range entries {
+		ctx := entry.ContextMap()
+		if entry.Message != expected[i].message || ctx[zaphandler.MessageTemplateKey] != nilIfEmpty(expected[i].template) {
+			t.Errorf("unexpected entry %q %v", entry.Message, ctx)
+		}
+	}
+
+	if ctx := entries[0].ContextMap(); ctx["user"] != "gopher" || ctx["ip"] != "127.0.0.1" {
+		t.Errorf("expected attrs to stay fields, got %v", ctx)
+	}
+
+	for _, delims := range [][2]string{{"", "}"}, {"{", ""}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic for delimiters %q", delims)
+				}
+			}()
+
+			zaphandler.TemplateDelims(delims[0], delims[1])
+		}()
+	}
+
+	core, obs = observer.New(zap.InfoLevel)
+	logger = slog.New(zaphandler.NewFromCore(core, zaphandler.MessageTemplate()))
+
+	logger.With("id", types.Int32(3)).WithGroup("req").With("method", "GET").
+		Info("{id} {method} {req.method} {path} {req.path}", "path", "/")
+	logger.Debug("dropped {user}", "user", types.Lazy(func() slog.Value {
+		t.Error("expected no rendering for dropped records")
+
+		return slog.Value{}
+	}))
+
+	if entries := obs.TakeAll(); len(entries) != 1 || entries[0].Message != "3 GET GET / /" {
+		t.Errorf("unexpected entries %v", entries)
+	}
+}
+
+func nilIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
+
+func TestDedup(t *testing.T) {
+	t.Parallel()
+
+	for _, args := range []struct {
+		window time.Duration
+		first  int
+	}{{window: 0, first: 1}, {window: time.Hour, first: 0}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic for %+v", args)
+				}
+			}()
+
+			zaphandler.NewDedup(zaphandler.NewFromCore(zapcore.NewNopCore()), args.window, args.first)
+		}()
+	}
+
+	core, obs := observer.New(zap.DebugLevel)
+	hand := zaphandler.NewDedup(zaphandler.NewFromCore(core), time.Hour, 2)
+	logger := slog.New(hand)
+
+	for i := 0; i < 10; i++ {
+		logger.With("w", 1).Error("flood", "i", i)
+	}
+
+	logger.Error("other")
+
+	if entries := obs.TakeAll(); len(entries) != 3 || entries[1].Message != "flood" || entries[2].Message != "other" {
+		t.Fatalf("expected the first 2 floods and other, got %+v", entries)
+	}
+
+	if err := hand.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := obs.TakeAll()
+	if len(entries) != 1 {
+		t.Fatalf("expected a summary, got %+v", entries)
+	}
+
+	expected := map[string]any{"w": int64(1), zaphandler.RepeatedKey: int64(8)}
+	if got := entries[0].ContextMap(); entries[0].Message != "flood" || !reflect.DeepEqual(expected, got) {
+		t.Errorf("unexpected summary %q %v", entries[0].Message, got)
+	}
+}
+
+func TestDedupWindow(t *testing.T) {
+	t.Parallel()
+
+	core, obs := observer.New(zap.DebugLevel)
+	logger := slog.New(zaphandler.NewDedup(zaphandler.NewFromCore(core), 10*time.Millisecond, 1))
+
+	for i := 0; i < 5; i++ {
+		logger.Warn("flood")
+	}
+
+	for deadline := time.Now().Add(time.Second); obs.Len() < 2 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	entries := obs.TakeAll()
+	if len(entries) != 2 || entries[1].ContextMap()[zaphandler.RepeatedKey] != int64(4) {
+		t.Errorf("expected the first flood and a summary, got %+v", entries)
+	}
+}
+
+type requestKey struct{}
+
+func requestID(ctx context.Context) (any, bool) {
+	id := ctx.Value(requestKey{})
+
+	return id, id != nil
+}
+
+func TestFlightRecorder(t *testing.T) {
+	t.Parallel()
+
+	core, obs := observer.New(zap.InfoLevel)
+	hand := zaphandler.NewFlightRecorder(zaphandler.NewFromCore(core), requestID, 2, slog.LevelInfo, slog.LevelError)
+	logger := slog.New(hand)
+
+	failed := context.WithValue(context.Background(), requestKey{}, 1)
+	passed := context.WithValue(context.Background(), requestKey{}, 2)
+
+	logger.DebugContext(passed, "kept")
+	logger.DebugContext(failed, "dropped")
+	logger.With("w", 1).WithGroup("g").DebugContext(failed, "first", "a", 1)
+	logger.DebugContext(failed, "second")
+	logger.Debug("without key")
+	logger.InfoContext(failed, "written")
+
+	if entries := obs.TakeAll(); len(entries) != 1 || entries[0].Message != "written" {
+		t.Fatalf("expected only written, got %+v", entries)
+	}
+
+	logger.ErrorContext(failed, "failed")
+	hand.Discard(passed)
+	logger.ErrorContext(passed, "passed")
+
+	entries := obs.TakeAll()
+
+	messages := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		messages = append(messages, entry.Message)
+	}
+
+	if expected := []string{"first", "second", "failed", "passed"}; !reflect.DeepEqual(expected, messages) {
+		t.Fatalf("expected %v, got %v", expected, messages)
+	}
+
+	expected := map[string]any{"w": int64(1), "g": map[string]any{"a": int64(1)}}
+	if got := entries[0].ContextMap(); !reflect.DeepEqual(expected, got) || entries[0].Level != zap.DebugLevel {
+		t.Errorf("unexpected flushed record %v %v", entries[0].Level, got)
+	}
+
+	logger.ErrorContext(failed, "again")
+
+	if entries := obs.TakeAll(); len(entries) != 1 {
+		t.Errorf("expected the buffer to be flushed once, got %+v", entries)
+	}
+}
+
+func TestFlightRecorderTee(t *testing.T) {
+	t.Parallel()
+
+	info, infoObs := observer.New(zap.InfoLevel)
+	alerts, alertObs := observer.New(zap.ErrorLevel)
+	logger := slog.New(zaphandler.NewFlightRecorder(
+		zaphandler.NewFromCore(zapcore.NewTee(info, alerts)), requestID, 4, slog.LevelInfo, slog.LevelError,
+	))
+
+	ctx := context.WithValue(context.Background(), requestKey{}, 1)
+	logger.DebugContext(ctx, "kept")
+	logger.ErrorContext(ctx, "failed")
+
+	if entries := infoObs.TakeAll(); len(entries) != 2 || entries[0].Level != zap.DebugLevel {
+		t.Errorf("expected the kept record in the info core, got %+v", entries)
+	}
+
+	if entries := alertObs.TakeAll(); len(entries) != 1 || entries[0].Message != "failed" {
+		t.Errorf("expected only the error in the alert core, got %+v", entries)
+	}
+}
+
+func TestWithLevel(t *testing.T) {
+	t.Parallel()
+
+	core, obs := observer.New(zap.InfoLevel)
+	logger := slog.New(zaphandler.NewFromCore(core))
+
+	debug := zaphandler.WithLevel(context.Background(), slog.LevelDebug)
+	warn := zaphandler.WithLevel(context.Background(), slog.LevelWarn)
+
+	logger.Debug("dropped")
+	logger.With("w", 1).DebugContext(debug, "escalated", "a", 1)
+	logger.InfoContext(warn, "raised")
+	logger.WarnContext(warn, "warned")
+
+	entries := obs.TakeAll()
+	if len(entries) != 2 || entries[0].Message != "escalated" || entries[1].Message != "warned" {
+		t.Fatalf("expected escalated and warned, got %+v", entries)
+	}
+
+	expected := map[string]any{"w": int64(1), "a": int64(1)}
+	if got := entries[0].ContextMap(); !reflect.DeepEqual(expected, got) || entries[0].Level != zap.DebugLevel {
+		t.Errorf("unexpected escalated record %v %v", entries[0].Level, got)
+	}
+
+	info, infoObs := observer.New(zap.InfoLevel)
+	errorOnly, errorObs := observer.New(zap.ErrorLevel)
+	slog.New(zaphandler.NewFromCore(zapcore.NewTee(info, errorOnly))).DebugContext(debug, "teed")
+
+	if entries := infoObs.TakeAll(); len(entries) != 1 || entries[0].Level != zap.DebugLevel {
+		t.Errorf("expected the debug record in the info core, got %+v", entries)
+	}
+
+	if errorObs.Len() != 0 {
+		t.Errorf("expected the error core to be left out, got %+v", errorObs.All())
+	}
+
+	logger.Log(debug, slog.LevelInfo+2, "between")
+	logger.Log(debug, slog.LevelError+2, "between")
+
+	if entries := obs.TakeAll(); len(entries) != 0 {
+		t.Errorf("expected levels between the known ones to be dropped, got %+v", entries)
+	}
+
+	off, offObs := observer.New(zapcore.InvalidLevel)
+	slog.New(zaphandler.NewFromCore(off)).ErrorContext(debug, "off")
+
+	if offObs.Len() != 0 {
+		t.Errorf("expected disabled cores to stay disabled, got %+v", offObs.All())
+	}
+}
+
+func TestRouter(t *testing.T) {
+	t.Parallel()
+
+	auditCore, audit := observer.New(zap.InfoLevel)
+	errorCore, errs := observer.New(zap.InfoLevel)
+	restCore, rest := observer.New(zap.InfoLevel)
+
+	logger := slog.New(zaphandler.NewRouter(
+		zaphandler.NewFromCore(restCore),
+		zaphandler.Route{Match: zaphandler.AttrEquals("req.audit", true), Handler: zaphandler.NewFromCore(auditCore)},
+		zaphandler.Route{Match: zaphandler.InGroup("audit"), Handler: zaphandler.NewFromCore(auditCore)},
+		zaphandler.Route{Match: zaphandler.LevelAtLeast(slog.LevelError), Handler: zaphandler.NewFromCore(errorCore)},
+		zaphandler.Route{Match: zaphandler.MessageHasPrefix("audit:"), Handler: zaphandler.NewFromCore(auditCore)},
+	))
+
+	req := logger.With("w", 1).WithGroup("req")
+
+	req.With("audit", true).Error("with attr")
+	req.Info("record attr", slog.Group("", "audit", true))
+	req.Info("other attr", "audit", false)
+	logger.WithGroup("audit").Info("group", "a", 1)
+	logger.Info("audit: message")
+	req.Error("failed", "a", 1)
+	logger.Info("top level attr", "audit", true)
+
+	messages := func(obs *observer.ObservedLogs) []string {
+		var out []string
+		for _, entry := range obs.All() {
+			out = append(out, entry.Message)
+		}
+
+		return out
+	}
+
+	if expected := []string{"with attr", "record attr", "group", "audit: message"}; !reflect.DeepEqual(expected, messages(audit)) {
+		t.Errorf("expected audit %v, got %v", expected, messages(audit))
+	}
+
+	if expected := []string{"failed"}; !reflect.DeepEqual(expected, messages(errs)) {
+		t.Errorf("expected errors %v, got %v", expected, messages(errs))
+	}
+
+	if expected := []string{"other attr", "top level attr"}; !reflect.DeepEqual(expected, messages(rest)) {
+		t.Errorf("expected rest %v, got %v", expected, messages(rest))
+	}
+
+	expected := map[string]any{"w": int64(1), "req": map[string]any{"audit": true}}
+	if got := audit.All()[0].ContextMap(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected attrs and groups on every route, got %v", got)
+	}
+
+	expected = map[string]any{"w": int64(1), "req": map[string]any{"a": int64(1)}}
+	if got := errs.All()[0].ContextMap(); !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected attrs and groups on every route, got %v", got)
+	}
+
+	// Routes not enabled for a record pass it on, without counting it as
+	// dropped.
+	errorOnly, _ := observer.New(zap.ErrorLevel)
+	errorHand := zaphandler.NewFromCore(errorOnly, zaphandler.CollectStats())
+	fallbackCore, fallback := observer.New(zap.InfoLevel)
+
+	slog.New(zaphandler.NewRouter(
+		zaphandler.NewFromCore(fallbackCore),
+		zaphandler.Route{Match: func(zaphandler.RouteRecord) bool { return true }, Handler: errorHand},
+	)).Info("passed on")
+
+	if fallback.Len() != 1 || errorHand.Stats().DroppedLevel != 0 {
+		t.Errorf("expected the record in the fallback only, got %d and %+v", fallback.Len(), errorHand.Stats())
+	}
+
+	var calls atomic.Int32
+
+	routed := zaphandler.RouteRecord{Record: slog.NewRecord(time.Time{}, slog.LevelInfo, "", 0)}
+	routed.AddAttrs(slog.Any("other", countValuer{calls: &calls}), slog.Any("req", countValuer{calls: &calls}))
+
+	if _, ok := routed.Attr("req.audit"); ok || calls.Load() != 1 {
+		t.Errorf("expected only the attr on the way to the key resolved, got %d calls", calls.Load())
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	now := time.Date(2023, time.August, 8, 10, 0, 0, 0, time.UTC)
+	core := JSONCore(&buf)
+
+	slog.New(zaphandler.NewFromCore(core, zaphandler.WithClock(FixedClock(now)))).Info("slog")
+	zap.New(core, zap.WithClock(FixedClock(now))).Info("zap")
+
+	got := ParseJSON(t, buf.Bytes())
+	if len(got) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(got))
+	}
+
+	if got[0][slog.TimeKey] != got[1][slog.TimeKey] || got[0][slog.TimeKey] != now.Format(time.RFC3339Nano) {
+		t.Errorf("expected time %v, got %v and %v", now, got[0][slog.TimeKey], got[1][slog.TimeKey])
+	}
+}
+
+func TestSourceField(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	logger := slog.New(zaphandler.NewFromCore(JSONCore(&buf), zaphandler.SourceField(slog.SourceKey)))
+	logger.WithGroup("g").Info("test", "a", 1)
+
+	got := ParseJSON(t, buf.Bytes())
+	if len(got) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(got))
+	}
+
+	source, ok := got[0][slog.SourceKey].(map[string]any)
+	if !ok {
+		t.Fatalf("expected source object, got %v", got[0])
+	}
+
+	if fn, _ := source["function"].(string); !strings.HasSuffix(fn, ".TestSourceField") {
+		t.Errorf("unexpected function %v", source["function"])
+	}
+
+	if file, _ := source["file"].(string); filepath.Base(file) != "handler_test.go" {
+		t.Errorf("unexpected file %v", source["file"])
+	}
+
+	if line, _ := source["line"].(float64); line < 1 {
+		t.Errorf("unexpected line %v", source["line"])
+	}
+}
+
+func TestRawJSON(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	logger := slog.New(zaphandler.NewFromCore(JSONCore(&buf)))
+	logger.Info("test", "valid", types.RawJSON(`{"a": [1, "b"]}`), "invalid", types.RawJSON(`{"a"`))
+
+	got := ParseJSON(t, buf.Bytes())
+	if len(got) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(got))
+	}
+
+	if valid := got[0]["valid"]; !reflect.DeepEqual(valid, map[string]any{"a": []any{float64(1), "b"}}) {
+		t.Errorf("expected inlined json, got %#v", valid)
+	}
+
+	if invalid := got[0]["invalid"]; invalid != `{"a"` {
+		t.Errorf("expected string fallback, got %#v", invalid)
+	}
+
+	buf.Reset()
+
+	cfg := zapcore.EncoderConfig{MessageKey: slog.MessageKey}
+	console := zapcore.NewCore(zapcore.NewConsoleEncoder(cfg), zapcore.AddSync(&buf), zapcore.DebugLevel)
+	slog.New(zaphandler.NewFromCore(console)).Info("test", "valid", types.RawJSON(`{"a": "<b>"}`))
+
+	if got, want := buf.String(), `test	{"valid": {"a":"<b>"}}`+"\n"; got != want {
+		t.Errorf("expected inlined json %q, got %q", want, got)
+	}
+
+	buf.Reset()
+
+	cfg.NewReflectedEncoder = types.ConsoleReflectedEncoder
+	console = zapcore.NewCore(zapcore.NewConsoleEncoder(cfg), zapcore.AddSync(&buf), zapcore.DebugLevel)
+	slog.New(zaphandler.NewFromCore(console)).Info("test", "valid", types.RawJSON(`{"a": "<b>"}`))
+
+	if got, want := buf.String(), `test	{"valid": "{\"a\": \"<b>\"}"}`+"\n"; got != want {
+		t.Errorf("expected escaped string %q, got %q", want, got)
+	}
+}
+
+func TestLazy(t *testing.T) {
+	t.Parallel()
+
+	var (
+		buf1, buf2 bytes.Buffer
+		calls      atomic.Int32
+	)
+
+	logger := slog.New(zaphandler.NewFromCore(zapcore.NewTee(JSONCore(&buf1), JSONCore(&buf2))))
+	logger = logger.With("with", types.Lazy(func() slog.Value {
+		calls.Add(1)
+
+		return slog.IntValue(1)
+	}))
+
+	value := types.Lazy(func() slog.Value {
+		calls.Add(1)
+
+		return slog.StringValue("value")
+	})
+	field := types.LazyField(func() zapcore.Field {
+		calls.Add(1)
+
+		return zap.Bool("field", true)
+	})
+
+	if calls.Load() != 0 {
+		t.Fatalf("expected no evaluation before logging, got %d", calls.Load())
+	}
+
+	logger.Info("test", "value", value, field, slog.Group("g", "nested", value))
+
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 evaluations, got %d", calls.Load())
+	}
+
+	for _, buf := range []*bytes.Buffer{&buf1, &buf2} {
+		got := ParseJSON(t, buf.Bytes())
+		if len(got) != 1 {
+			t.Fatalf("expected 1 record, got %d", len(got))
+		}
+
+		expected := map[string]any{"with": float64(1), "value": "value", "field": true, "g": map[string]any{"nested": "value"}}
+		for key, val := range expected {
+			if !reflect.DeepEqual(got[0][key], val) {
+				t.Errorf("mismatched %s: expected %v, got %v", key, val, got[0][key])
+			}
+		}
+	}
+
+	// Values are evaluated again for every record, attrs of WithAttrs and of
+	// open groups included.
+	calls.Store(0)
+	logger.WithGroup("o").With("open", value).Info("test", "value", value)
+	logger.Info("test")
+
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 evaluations for the later records, got %d", calls.Load())
+	}
+
+	// The attr rules apply to the values, and fields of With keep their order.
+	var buf bytes.Buffer
+
+	core := zaphandler.NewCore(zapcore.NewJSONEncoder, zapcore.EncoderConfig{MessageKey: slog.MessageKey},
+		zapcore.AddSync(&buf), zapcore.DebugLevel)
+	slog.New(zaphandler.NewFromCore(core)).
+		With("a", 1, "lz", types.Lazy(func() slog.Value { return slog.IntValue(2) }), "b", 3).
+		WithGroup("g").
+		Info("test", "c", 4,
+			"e", types.Lazy(func() slog.Value { return slog.GroupValue() }),
+			slog.Any("", types.Lazy(func() slog.Value { return slog.GroupValue(slog.Int("x", 1)) })))
+
+	if expected := `{"msg":"test","a":1,"lz":2,"b":3,"g":{"c":4,"x":1}}` + "\n"; buf.String() != expected {
+		t.Errorf("expected %s, got %s", expected, buf.String())
+	}
 }
@@ -0,0 +1,48 @@
+package zaphandler
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type EntryHook func(zapcore.Entry, slog.Record) error
+
+// Hooks runs the hooks for every record written by the core, like zap.Hooks.
+// Their errors are returned from Handle.
+func Hooks(hooks ...EntryHook) Option {
+	return func(h *ZapHandler) {
+		h.entryHooks = append(slices.Clip(h.entryHooks), hooks...)
+	}
+}
+
+// WriteHooks runs the hooks after every record written by the core, before
+// the hook of LevelHook if any.
+func WriteHooks(hooks ...zapcore.CheckWriteHook) Option {
+	return func(h *ZapHandler) {
+		h.writeHooks = append(slices.Clip(h.writeHooks), hooks...)
+	}
+}
+
+type afterWrite struct {
+	hand *ZapHandler
+	rec  slog.Record
+	hook zapcore.CheckWriteHook
+	err  error
+}
+
+func (a *afterWrite) OnWrite(checked *zapcore.CheckedEntry, fields []zapcore.Field) {
+	for _, hook := range a.hand.writeHooks {
+		hook.OnWrite(checked, fields)
+	}
+
+	for _, hook := range a.hand.entryHooks {
+		a.err = errors.Join(a.err, hook(checked.Entry, a.rec))
+	}
+
+	if a.hook != nil {
+		a.hook.OnWrite(checked, fields)
+	}
+}
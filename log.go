@@ -0,0 +1,56 @@
+package zaphandler
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"runtime"
+	"time"
+)
+
+// LogPC logs through logger as if it was called from pc, for wrappers which
+// already know the program counter of their caller.
+func LogPC(ctx context.Context, logger *slog.Logger, pc uintptr, level slog.Level, msg string, args ...any) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	rec := slog.NewRecord(time.Now(), level, msg, pc)
+	rec.Add(args...)
+
+	_ = logger.Handler().Handle(ctx, rec)
+}
+
+// LogSkip logs through logger with the caller skip frames above the caller
+// of LogSkip, like zap.AddCallerSkip.
+func LogSkip(ctx context.Context, logger *slog.Logger, skip int, level slog.Level, msg string, args ...any) {
+	var pcs [1]uintptr
+
+	// skip runtime.Callers and LogSkip
+	runtime.Callers(skip+2, pcs[:])
+
+	LogPC(ctx, logger, pcs[0], level, msg, args...)
+}
+
+// Panic logs at LevelPanic and panics, like zap.Logger.Panic. Handlers with
+// PanicLevel set panic on their own after writing.
+func Panic(logger *slog.Logger, msg string, args ...any) {
+	LogSkip(context.Background(), logger, 1, LevelPanic, msg, args...)
+	panic(msg)
+}
+
+// Fatal logs at LevelFatal and exits with status 1, like zap.Logger.Fatal.
+// Handlers with a Sync method, like ZapHandler, are synced before exiting.
+func Fatal(logger *slog.Logger, msg string, args ...any) {
+	LogSkip(context.Background(), logger, 1, LevelFatal, msg, args...)
+
+	if syncer, ok := logger.Handler().(interface{ Sync() error }); ok {
+		_ = syncer.Sync()
+	}
+
+	os.Exit(1)
+}
@@ -0,0 +1,216 @@
+package zaphandler
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mrchanchal.com/zaphandler/types"
+	"go.uber.org/zap/zapcore"
+)
+
+// MessageKey moves the message of records into an attr with key, leaving the
+// message of the entry empty.
+func MessageKey(key string) Option { return func(h *ZapHandler) { h.messageKey = key } }
+
+// MessageWithAttrs appends the attrs of the handler and of records to the
+// message as key=value pairs, keeping them as fields too. Keys are prefixed
+// with their groups, and values are shown the way the fields encode them.
+func MessageWithAttrs() Option { return func(h *ZapHandler) { h.messageAttrs = true } }
+
+// MessageFrom takes the message of records without one from their attr with
+// key, which is then left out of the fields.
+func MessageFrom(key string) Option { return func(h *ZapHandler) { h.messageFrom = key } }
+
+// rendersAttrs reports whether messages are rendered with the values of attrs.
+func (hand *ZapHandler) rendersAttrs() bool { return hand.messageAttrs || hand.templates != nil }
+
+// message renders the message of rec with conv, returning the template the
+// message was rendered from, if any.
+func (hand *ZapHandler) message(conv types.Converter, rec slog.Record) (slog.Record, string) {
+	var (
+		template string
+		tmpl     *templateT
+	)
+
+	if hand.templates != nil {
+		tmpl = hand.templates.get(rec.Message)
+	}
+
+	if !hand.messageAttrs && (tmpl == nil || !tmpl.hasKeys && !tmpl.escaped) {
+		return rec, ""
+	}
+
+	var (
+		pairs  []pairT
+		prefix string
+	)
+
+	if hand.messageAttrs || tmpl.hasKeys {
+		pairs, prefix = hand.pairs(conv, rec)
+	}
+
+	if tmpl != nil {
+		switch {
+		case tmpl.hasKeys:
+			template, rec.Message = rec.Message, tmpl.render(pairs, prefix)
+		case tmpl.escaped:
+			rec.Message = tmpl.render(nil, "")
+		}
+	}
+
+	if hand.messageAttrs && len(pairs) > 0 {
+		var msg strings.Builder
+
+		msg.WriteString(rec.Message)
+
+		for _, pair := range pairs {
+			msg.WriteString(" ")
+			msg.WriteString(pair.key)
+			msg.WriteString("=")
+			msg.WriteString(quote(pair.value))
+		}
+
+		rec.Message = strings.TrimPrefix(msg.String(), " ")
+	}
+
+	return rec, template
+}
+
+// pairT is a value of a field as messages show it, under the keys of its
+// groups and its own joined by dots.
+type pairT struct{ key, value string }
+
+// pairs returns the fields of the handler and of rec as pairs, in the order
+// they are added, along with the prefix of the groups open for rec.
+func (hand *ZapHandler) pairs(conv types.Converter, rec slog.Record) ([]pairT, string) {
+	pairs := appendPairs(nil, "", conv.Rebind(hand.withFields))
+
+	var prefix string
+
+	for _, grp := range hand.groups {
+		prefix = joinKey(prefix, grp.name)
+		pairs = appendPairs(pairs, prefix, conv.Rebind(grp.fields))
+	}
+
+	var (
+		fields []zapcore.Field
+		kept   int
+	)
+
+	rec.Attrs(func(attr slog.Attr) bool {
+		if maxAttrs := conv.Limits.MaxAttrs; maxAttrs > 0 && kept >= maxAttrs {
+			return false
+		}
+
+		kept++
+		fields = conv.AppendFields(fields, attr)
+
+		return true
+	})
+
+	return appendPairs(pairs, prefix, fields), prefix
+}
+
+// appendPairs appends the pairs of fields to pairs, encoding each field on its
+// own to keep their order.
+func appendPairs(pairs []pairT, prefix string, fields []zapcore.Field) []pairT {
+	for _, field := range fields {
+		enc := zapcore.NewMapObjectEncoder()
+		field.AddTo(enc)
+
+		pairs = appendValue(pairs, prefix, enc.Fields)
+	}
+
+	return pairs
+}
+
+func appendValue(pairs []pairT, key string, val any) []pairT {
+	obj, ok := val.(map[string]any)
+	if !ok {
+		return append(pairs, pairT{key: key, value: formatValue(val)})
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		pairs = appendValue(pairs, joinKey(key, k), obj[k])
+	}
+
+	return pairs
+}
+
+// formatValue formats a value of a zapcore.MapObjectEncoder, which holds the
+// encoded forms of errors, fmt.Stringers and such.
+func formatValue(val any) string {
+	switch typed := val.(type) {
+	case string:
+		return typed
+	case []byte:
+		return base64.StdEncoding.EncodeToString(typed)
+	case time.Time:
+		return typed.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(typed)
+	}
+}
+
+func joinKey(prefix, key string) string {
+	switch {
+	case prefix == "":
+		return key
+	case key == "":
+		return prefix
+	default:
+		return prefix + "." + key
+	}
+}
+
+func (hand *ZapHandler) messageFromAttr(rec slog.Record) slog.Record {
+	ret := slog.NewRecord(rec.Time, rec.Level, "", rec.PC)
+
+	rec.Attrs(func(attr slog.Attr) bool {
+		if attr.Key == hand.messageFrom && ret.Message == "" {
+			ret.Message = attr.Value.Resolve().String()
+		} else {
+			ret.AddAttrs(attr)
+		}
+
+		return true
+	})
+
+	return ret
+}
+
+// quote quotes values the way slog.TextHandler does.
+func quote(val string) string {
+	if needsQuoting(val) {
+		return strconv.Quote(val)
+	}
+
+	return val
+}
+
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+
+	return false
+}
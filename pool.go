@@ -1,7 +1,6 @@
 package zaphandler
 
 import (
-	"log/slog"
 	"runtime"
 	"sync"
 
@@ -11,19 +10,26 @@ import (
 const (
 	invalidType = "invalid pool type"
 	poolSize    = 32
+	stackDepth  = 64
 )
 
 type (
 	poolS[T any] struct{ item []T }
-	poolT        struct{ stack, fields, attrs sync.Pool }
+	poolT        struct {
+		stack, frames sync.Pool
+		fields        sync.Pool
+		callers       *callerCache
+		format        callerFormat
+	}
 )
 
 func initPool[T any](f func() []T) func() any { return func() any { return &poolS[T]{item: f()} } }
 func newPool() *poolT {
 	return &poolT{
-		stack:  sync.Pool{New: initPool(func() []uintptr { return make([]uintptr, 1) })},
-		fields: sync.Pool{New: initPool(func() []zapcore.Field { return make([]zapcore.Field, 0, poolSize) })},
-		attrs:  sync.Pool{New: initPool(func() []slog.Attr { return make([]slog.Attr, 0, poolSize) })},
+		stack:   sync.Pool{New: initPool(func() []uintptr { return make([]uintptr, 1) })},
+		frames:  sync.Pool{New: initPool(func() []uintptr { return make([]uintptr, stackDepth) })},
+		fields:  sync.Pool{New: initPool(func() []zapcore.Field { return make([]zapcore.Field, 0, poolSize) })},
+		callers: newCallerCache(cacheSize),
 	}
 }
 
@@ -32,6 +38,10 @@ func (p *poolT) caller(programCounter uintptr, addSource bool) zapcore.EntryCall
 		return zapcore.EntryCaller{}
 	}
 
+	if caller, ok := p.callers.get(programCounter); ok {
+		return caller
+	}
+
 	stack, ok := p.stack.Get().(*poolS[uintptr])
 	if !ok || len(stack.item) != 1 {
 		panic(invalidType)
@@ -42,33 +52,56 @@ func (p *poolT) caller(programCounter uintptr, addSource bool) zapcore.EntryCall
 	stack.item[0] = programCounter
 	frame, _ := runtime.CallersFrames((stack.item)).Next()
 
-	return zapcore.EntryCaller{
+	caller := p.format.apply(zapcore.EntryCaller{
 		Defined:  true,
 		PC:       frame.PC,
 		File:     frame.File,
 		Line:     frame.Line,
 		Function: frame.Function,
-	}
+	})
+
+	p.callers.put(programCounter, caller)
+
+	return caller
 }
 
-func (p *poolT) withFields(fieldsF func([]zapcore.Field)) {
-	fields, ok := p.fields.Get().(*poolS[zapcore.Field])
-	if !ok {
+// skipCallers walks skip frames up from programCounter on the current stack.
+// It only finds programCounter while the logging call is still running, and
+// returns it unchanged otherwise, or when the stack ends first. Stacks deeper
+// than stackDepth are walked again with a larger buffer.
+func (p *poolT) skipCallers(programCounter uintptr, skip int) uintptr {
+	if skip < 1 || programCounter == 0 {
+		return programCounter
+	}
+
+	frames, ok := p.frames.Get().(*poolS[uintptr])
+	if !ok || len(frames.item) != stackDepth {
 		panic(invalidType)
 	}
 
-	defer p.fields.Put(fields)
+	defer p.frames.Put(frames)
 
-	fieldsF(fields.item)
+	for pcs := frames.item; ; pcs = make([]uintptr, 2*len(pcs)) {
+		n := runtime.Callers(1, pcs)
+		for i, pc := range pcs[:n] {
+			if pc == programCounter && i+skip < n {
+				return pcs[i+skip]
+			}
+		}
+
+		if n < len(pcs) {
+			return programCounter
+		}
+	}
 }
 
-func (p *poolT) withAttrs(attrF func([]slog.Attr)) {
-	attrs, ok := p.attrs.Get().(*poolS[slog.Attr])
+func (p *poolT) withFields(fieldsF func([]zapcore.Field)) {
+	fields, ok := p.fields.Get().(*poolS[zapcore.Field])
 	if !ok {
 		panic(invalidType)
 	}
 
-	defer p.attrs.Put(attrs)
+	defer p.fields.Put(fields)
 
-	attrF(attrs.item)
+	fieldsF(fields.item)
 }
@@ -0,0 +1,94 @@
+package zaphandler
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"sync"
+
+	"go.mrchanchal.com/zaphandler/types"
+	"go.uber.org/zap/zapcore"
+)
+
+// ReflectFallback is a Go type which record attrs converted with reflection.
+type ReflectFallback struct {
+	Type   string
+	Count  uint64
+	Caller zapcore.EntryCaller
+}
+
+type reflectReportT struct {
+	sync.Mutex
+	types map[reflect.Type]*ReflectFallback
+}
+
+// ReportReflect makes the handler record which types of record attrs fell
+// back to zapcore.ReflectType, to find values worth a slog.LogValuer. It is
+// meant for development, as it looks into every converted record.
+func ReportReflect() Option {
+	return func(h *ZapHandler) { h.reflected = &reflectReportT{types: map[reflect.Type]*ReflectFallback{}} }
+}
+
+func (r *reflectReportT) record(fields []zapcore.Field, caller func() zapcore.EntryCaller) {
+	types.WalkReflected(fields, func(val any) {
+		typ := reflect.TypeOf(val)
+		if typ == nil {
+			return
+		}
+
+		r.Lock()
+		defer r.Unlock()
+
+		if fallback, ok := r.types[typ]; ok {
+			fallback.Count++
+
+			return
+		}
+
+		r.types[typ] = &ReflectFallback{Type: typ.String(), Count: 1, Caller: caller()}
+	})
+}
+
+// ReflectReport returns the types recorded with ReportReflect, the most
+// frequent first.
+func (hand *ZapHandler) ReflectReport() []ReflectFallback {
+	if hand.reflected == nil {
+		return nil
+	}
+
+	hand.reflected.Lock()
+	defer hand.reflected.Unlock()
+
+	ret := make([]ReflectFallback, 0, len(hand.reflected.types))
+	for _, fallback := range hand.reflected.types {
+		ret = append(ret, *fallback)
+	}
+
+	slices.SortFunc(ret, func(a, b ReflectFallback) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Type, b.Type)
+	})
+
+	return ret
+}
+
+// WriteReflectReport writes ReflectReport to out, one type per line.
+func (hand *ZapHandler) WriteReflectReport(out io.Writer) error {
+	for _, fallback := range hand.ReflectReport() {
+		site := "unknown"
+		if fallback.Caller.Defined {
+			site = fallback.Caller.String()
+		}
+
+		if _, err := fmt.Fprintf(out, "%d\t%s\t%s\n", fallback.Count, fallback.Type, site); err != nil {
+			return fmt.Errorf("error writing reflect report: %w", err)
+		}
+	}
+
+	return nil
+}
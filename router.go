@@ -0,0 +1,199 @@
+package zaphandler
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+type (
+	// Predicate picks the records a Route takes.
+	Predicate func(RouteRecord) bool
+
+	// Route sends the records matching Match to Handler.
+	Route struct {
+		Match   Predicate
+		Handler *ZapHandler
+	}
+
+	// RouteRecord is a record as Predicates see it, along with the groups and
+	// attrs of the Router it went to.
+	RouteRecord struct {
+		slog.Record
+		Groups []string
+		attrs  []routeAttr
+	}
+
+	routeAttr struct {
+		prefix string
+		attr   slog.Attr
+	}
+)
+
+var _ slog.Handler = (*Router)(nil)
+
+// Router sends each record to the Handler of the first Route matching it and
+// enabled for its level, or to the fallback when none does. Attrs and groups are added to the Handlers
+// of every Route, so records end up the same whichever one takes them.
+type Router struct {
+	routes   []Route
+	fallback *ZapHandler
+	groups   []string
+	attrs    []routeAttr
+}
+
+// NewRouter returns a Router over routes, with fallback taking the records no
+// route matches. Such records are dropped if fallback is nil.
+func NewRouter(fallback *ZapHandler, routes ...Route) *Router {
+	return &Router{routes: routes, fallback: fallback}
+}
+
+// LevelAtLeast matches records at lvl and above.
+func LevelAtLeast(lvl slog.Level) Predicate {
+	return func(rec RouteRecord) bool { return rec.Level >= lvl }
+}
+
+// MessageHasPrefix matches records with messages starting with prefix.
+func MessageHasPrefix(prefix string) Predicate {
+	return func(rec RouteRecord) bool { return strings.HasPrefix(rec.Message, prefix) }
+}
+
+// InGroup matches records logged with the group name open.
+func InGroup(name string) Predicate {
+	return func(rec RouteRecord) bool { return slices.Contains(rec.Groups, name) }
+}
+
+// AttrEquals matches records with the attr key holding value, keys being as
+// for RouteRecord.Attr.
+func AttrEquals(key string, value any) Predicate {
+	want := slog.AnyValue(value)
+
+	return func(rec RouteRecord) bool {
+		val, ok := rec.Attr(key)
+
+		return ok && val.Equal(want)
+	}
+}
+
+// Attr returns the resolved value of the attr key, looking at the attrs of the
+// record before those of the Router. Keys of attrs within groups are the group
+// names and the key joined by dots, like "request.id".
+func (rec RouteRecord) Attr(key string) (slog.Value, bool) {
+	var (
+		val   slog.Value
+		found bool
+	)
+
+	prefix := strings.Join(rec.Groups, ".")
+
+	rec.Attrs(func(attr slog.Attr) bool {
+		val, found = findAttr(prefix, attr, key)
+
+		return !found
+	})
+
+	for i := len(rec.attrs) - 1; i >= 0 && !found; i-- {
+		val, found = findAttr(rec.attrs[i].prefix, rec.attrs[i].attr, key)
+	}
+
+	return val, found
+}
+
+// findAttr looks for key within attr, resolving only the attr matching key and
+// the groups on the way to it.
+func findAttr(prefix string, attr slog.Attr, key string) (slog.Value, bool) {
+	full := joinKey(prefix, attr.Key)
+
+	if attr.Key != "" && full == key {
+		return attr.Value.Resolve(), true
+	}
+
+	if full != "" && !strings.HasPrefix(key, full+".") {
+		return slog.Value{}, false
+	}
+
+	val := attr.Value.Resolve()
+	if val.Kind() != slog.KindGroup {
+		return slog.Value{}, false
+	}
+
+	for _, member := range val.Group() {
+		if val, ok := findAttr(full, member, key); ok {
+			return val, true
+		}
+	}
+
+	return slog.Value{}, false
+}
+
+// Enabled reports whether any Handler takes records at lvl. Only the fallback
+// counts records dropped here in its stats, as routes may never see them.
+func (r *Router) Enabled(ctx context.Context, lvl slog.Level) bool {
+	for _, route := range r.routes {
+		if route.Handler.enabled(ctx, lvl) {
+			return true
+		}
+	}
+
+	return r.fallback != nil && r.fallback.Enabled(ctx, lvl)
+}
+
+func (r *Router) Handle(ctx context.Context, rec slog.Record) error {
+	routed := RouteRecord{Record: rec, Groups: r.groups, attrs: r.attrs}
+
+	for _, route := range r.routes {
+		if route.Handler.enabled(ctx, rec.Level) && route.Match(routed) {
+			return route.Handler.Handle(ctx, rec)
+		}
+	}
+
+	if r.fallback == nil {
+		return nil
+	}
+
+	return r.fallback.Handle(ctx, rec)
+}
+
+func (r *Router) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return r
+	}
+
+	cloned := r.with(func(hand *ZapHandler) slog.Handler { return hand.WithAttrs(attrs) })
+
+	prefix := strings.Join(r.groups, ".")
+	for _, attr := range attrs {
+		cloned.attrs = append(slices.Clip(cloned.attrs), routeAttr{prefix: prefix, attr: attr})
+	}
+
+	return cloned
+}
+
+func (r *Router) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return r
+	}
+
+	cloned := r.with(func(hand *ZapHandler) slog.Handler { return hand.WithGroup(name) })
+	cloned.groups = append(slices.Clip(cloned.groups), name)
+
+	return cloned
+}
+
+// with returns a copy of r with the Handlers of every Route and the fallback
+// replaced by derive.
+func (r *Router) with(derive func(*ZapHandler) slog.Handler) *Router {
+	cloned := *r
+	cloned.routes = slices.Clone(r.routes)
+
+	for i := range cloned.routes {
+		cloned.routes[i].Handler, _ = derive(cloned.routes[i].Handler).(*ZapHandler)
+	}
+
+	if cloned.fallback != nil {
+		cloned.fallback, _ = derive(cloned.fallback).(*ZapHandler)
+	}
+
+	return &cloned
+}
@@ -0,0 +1,113 @@
+package zaphandler
+
+import (
+	"expvar"
+	"sync/atomic"
+	"time"
+
+	"go.mrchanchal.com/zaphandler/types"
+	"go.uber.org/zap/zapcore"
+)
+
+// Stats is a snapshot of what a handler, and every handler derived from it
+// with WithAttrs or WithGroup, did so far.
+type Stats struct {
+	// Handled counts records written to the core.
+	Handled uint64
+	// DroppedLevel counts records left out by Enabled or by the core.
+	DroppedLevel uint64
+	// DroppedContext counts records dropped for a done context.
+	DroppedContext uint64
+	// WriteErrors counts write errors reported by zap.
+	WriteErrors uint64
+	// ReflectFallbacks counts values converted with zapcore.ReflectType,
+	// within groups and attrs added by WithAttrs too.
+	ReflectFallbacks uint64
+	// ConversionTime is the time spent converting record attrs into fields.
+	ConversionTime time.Duration
+}
+
+type statsT struct {
+	handled, droppedLevel, droppedContext atomic.Uint64
+	writeErrors, reflectFallbacks         atomic.Uint64
+	conversionTime                        atomic.Int64
+}
+
+// CollectStats makes the handler count what it does, for Stats.
+func CollectStats() Option { return func(h *ZapHandler) { h.stats = &statsT{} } }
+
+// Stats returns a snapshot of the counters. It is zero unless the handler was
+// created with CollectStats.
+func (hand *ZapHandler) Stats() Stats {
+	if hand.stats == nil {
+		return Stats{}
+	}
+
+	return Stats{
+		Handled:          hand.stats.handled.Load(),
+		DroppedLevel:     hand.stats.droppedLevel.Load(),
+		DroppedContext:   hand.stats.droppedContext.Load(),
+		WriteErrors:      hand.stats.writeErrors.Load(),
+		ReflectFallbacks: hand.stats.reflectFallbacks.Load(),
+		ConversionTime:   time.Duration(hand.stats.conversionTime.Load()),
+	}
+}
+
+// PublishExpvar publishes Stats as the expvar variable name. Like
+// expvar.Publish, it panics if name is already in use.
+func (hand *ZapHandler) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() any { return hand.Stats() }))
+}
+
+func (s *statsT) dropLevel() {
+	if s != nil {
+		s.droppedLevel.Add(1)
+	}
+}
+
+func (s *statsT) dropContext() {
+	if s != nil {
+		s.droppedContext.Add(1)
+	}
+}
+
+func (s *statsT) converted(start time.Time, fields []zapcore.Field) {
+	if s == nil {
+		return
+	}
+
+	s.conversionTime.Add(int64(time.Since(start)))
+	s.reflectedFields(fields)
+}
+
+func (s *statsT) reflectedFields(fields []zapcore.Field) {
+	if s == nil {
+		return
+	}
+
+	types.WalkReflected(fields, func(any) { s.reflectFallbacks.Add(1) })
+}
+
+// convertedWith accounts for the reflected values of fields converted by
+// WithAttrs, which have no caller.
+func (hand *ZapHandler) convertedWith(fields []zapcore.Field) {
+	hand.stats.reflectedFields(fields)
+
+	if hand.reflected != nil {
+		hand.reflected.record(fields, func() zapcore.EntryCaller { return zapcore.EntryCaller{} })
+	}
+}
+
+func (s *statsT) written(errOut *Error) {
+	if s == nil {
+		return
+	}
+
+	s.handled.Add(1)
+
+	for _, err := range errOut.errs {
+		if _, ok := err.(*WriteError); ok { //nolint:errorlint
+			s.writeErrors.Add(1)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package zaphandler
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// MessageTemplateKey holds the template of messages rendered by
+// MessageTemplate, to group records in the backend.
+const MessageTemplateKey = "message_template"
+
+const templateCacheSize = 4096
+
+type (
+	segmentT struct {
+		literal string
+		key     string
+	}
+	templateT struct {
+		segments    []segmentT
+		hasKeys     bool
+		escaped     bool
+		left, right string
+	}
+	templateCacheT struct {
+		left, right string
+		size        atomic.Int64
+		parsed      sync.Map
+	}
+)
+
+// MessageTemplate renders messages like "user {user} logged in" with the
+// attrs of the record and the handler, which stay fields as well. Keys of
+// attrs within groups are joined by dots, like "request.id". The template is added under
+// MessageTemplateKey. "{{" and "}}" stand for a literal "{" and "}".
+func MessageTemplate() Option { return TemplateDelims("{", "}") }
+
+// TemplateDelims is MessageTemplate with other delimiters. It panics if left or
+// right is empty.
+func TemplateDelims(left, right string) Option {
+	if left == "" || right == "" {
+		panic("zaphandler: empty template delimiter")
+	}
+
+	return func(h *ZapHandler) { h.templates = &templateCacheT{left: left, right: right} }
+}
+
+func (c *templateCacheT) get(msg string) *templateT {
+	if got, ok := c.parsed.Load(msg); ok {
+		tmpl, _ := got.(*templateT)
+
+		return tmpl
+	}
+
+	tmpl := parseTemplate(msg, c.left, c.right)
+
+	// Messages built at runtime would grow the cache forever.
+	if c.size.Add(1) <= templateCacheSize {
+		c.parsed.Store(msg, tmpl)
+	}
+
+	return tmpl
+}
+
+func parseTemplate(msg, left, right string) *templateT {
+	tmpl := &templateT{left: left, right: right}
+
+	var literal strings.Builder
+
+	for rest := msg; rest != ""; {
+		// A doubled right delimiter outside of keys stands for a literal one.
+		if at, escape := strings.Index(rest, left), strings.Index(rest, right+right); escape >= 0 && (at < 0 || escape < at) {
+			literal.WriteString(rest[:escape] + right)
+			tmpl.escaped = true
+			rest = rest[escape+2*len(right):]
+
+			continue
+		}
+
+		before, after, found := strings.Cut(rest, left)
+		literal.WriteString(before)
+
+		if !found {
+			break
+		}
+
+		if strings.HasPrefix(after, left) {
+			literal.WriteString(left)
+			tmpl.escaped = true
+			rest = after[len(left):]
+
+			continue
+		}
+
+		key, remaining, closed := strings.Cut(after, right)
+		if !closed || key == "" {
+			literal.WriteString(left)
+			rest = after
+
+			continue
+		}
+
+		tmpl.segments = append(tmpl.segments, segmentT{literal: literal.String(), key: key})
+		tmpl.hasKeys = true
+		literal.Reset()
+
+		rest = remaining
+	}
+
+	if literal.Len() > 0 {
+		tmpl.segments = append(tmpl.segments, segmentT{literal: literal.String()})
+	}
+
+	return tmpl
+}
+
+// render fills the template with the values of pairs, the last one of a key
+// winning. Keys are looked up within the groups of prefix first, then from
+// the top. Keys without a value are left as they are.
+func (t *templateT) render(pairs []pairT, prefix string) string {
+	var msg strings.Builder
+
+	for _, seg := range t.segments {
+		msg.WriteString(seg.literal)
+
+		if seg.key == "" {
+			continue
+		}
+
+		val, ok := lookupPair(pairs, joinKey(prefix, seg.key))
+		if !ok && prefix != "" {
+			val, ok = lookupPair(pairs, seg.key)
+		}
+
+		if !ok {
+			msg.WriteString(t.left + seg.key + t.right)
+
+			continue
+		}
+
+		msg.WriteString(val)
+	}
+
+	return msg.String()
+}
+
+func lookupPair(pairs []pairT, key string) (string, bool) {
+	for i := len(pairs) - 1; i >= 0; i-- {
+		if pairs[i].key == key {
+			return pairs[i].value, true
+		}
+	}
+
+	return "", false
+}
@@ -11,9 +11,180 @@ var _ zapcore.ObjectMarshaler = (Group)(nil)
 type Group []slog.Attr
 
 func (g Group) MarshalLogObject(enc zapcore.ObjectEncoder) error {
-	for _, attr := range g {
-		NewFieldType(attr.Value).Field(attr.Key).AddTo(enc)
+	return converterGroup{attrs: g}.MarshalLogObject(enc)
+}
+
+var _ zapcore.ObjectMarshaler = converterGroup{}
+
+// converterGroup is a Group converted with a Converter other than the
+// default one.
+type converterGroup struct {
+	conv  Converter
+	attrs Group
+}
+
+func (g converterGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, attr := range g.attrs {
+		g.conv.eachField(attr, func(f zapcore.Field) { f.AddTo(enc) })
+	}
+
+	return nil
+}
+
+func (c Converter) group(attrs []slog.Attr) zapcore.ObjectMarshaler {
+	if c == (Converter{}) {
+		return Group(attrs)
+	}
+
+	nested := c
+	nested.depth++
+
+	return converterGroup{conv: nested, attrs: attrs}
+}
+
+// Empty reports whether the group has no attrs left to emit once the slog
+// attribute rules are applied.
+func (g Group) Empty() bool {
+	return len(prune(g)) == 0
+}
+
+// prune applies the slog attribute rules to attrs, resolving every value once
+// so encoding the result does not call any LogValuer again. attrs is returned
+// as it is when no rule changes it.
+func prune(attrs []slog.Attr) []slog.Attr {
+	var ret []slog.Attr
+
+	for i, attr := range attrs {
+		attr, keep, changed := pruneAttr(attr)
+		if ret == nil && (changed || !keep) {
+			ret = append(make([]slog.Attr, 0, len(attrs)), attrs[:i]...)
+		}
+
+		if ret != nil && keep {
+			ret = append(ret, attr)
+		}
+	}
+
+	if ret == nil {
+		return attrs
+	}
+
+	return ret
+}
+
+// pruneAttr resolves attr and prunes its group, reporting whether it is to be
+// kept and whether it changed.
+func pruneAttr(attr slog.Attr) (slog.Attr, bool, bool) {
+	// Lazy values can not be checked without evaluating them.
+	if _, ok := (Converter{}).lazy(attr); ok {
+		return attr, true, false
+	}
+
+	changed := attr.Value.Kind() == slog.KindLogValuer
+	attr.Value = resolve(attr.Value)
+
+	// If an Attr's key and value are both the zero value, ignore the Attr.
+	if attr.Equal(slog.Attr{}) {
+		return attr, false, true
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		grp := attr.Value.Group()
+
+		// If a group has no Attrs (even if it has a non-empty key), ignore it.
+		pruned := prune(grp)
+		if len(pruned) == 0 {
+			return attr, false, true
+		}
+
+		if len(pruned) != len(grp) || &pruned[0] != &grp[0] {
+			attr.Value, changed = slog.GroupValue(pruned...), true
+		}
+	}
+
+	return attr, true, changed
+}
+
+// AppendFields appends the fields for attrs to fields, applying the slog
+// attribute rules the same way nested groups do.
+func AppendFields(fields []zapcore.Field, attrs ...slog.Attr) []zapcore.Field {
+	return Converter{}.AppendFields(fields, attrs...)
+}
+
+// AppendFields is the package level AppendFields using the converter.
+func (c Converter) AppendFields(fields []zapcore.Field, attrs ...slog.Attr) []zapcore.Field {
+	for _, attr := range attrs {
+		c.eachField(attr, func(f zapcore.Field) { fields = append(fields, f) })
+	}
+
+	return fields
+}
+
+func (c Converter) eachField(attr slog.Attr, fieldF func(zapcore.Field)) {
+	// Lazy values stay unresolved until they are encoded.
+	if field, ok := c.lazy(attr); ok {
+		fieldF(field)
+
+		return
+	}
+
+	// Values are resolved once here, nested groups included, as encoding the
+	// field goes through the resolved values only.
+	attr, keep, _ := pruneAttr(attr)
+	if !keep {
+		return
+	}
+
+	// If a group's key is empty, inline the group's Attrs.
+	if attr.Key == "" && attr.Value.Kind() == slog.KindGroup {
+		for _, a := range attr.Value.Group() {
+			c.eachField(a, fieldF)
+		}
+
+		return
+	}
+
+	fieldF(c.FieldType(attr.Value).Field(attr.Key))
+}
+
+var _ zapcore.ObjectMarshaler = (Fields)(nil)
+
+// Fields marshals already converted fields as a nested object.
+type Fields []zapcore.Field
+
+func (f Fields) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, field := range f {
+		field.AddTo(enc)
 	}
 
 	return nil
 }
+
+// WalkReflected calls walkF with the value of every field converted with
+// zapcore.ReflectType, looking into nested groups as well. RawJSON is left
+// out, as it only uses zapcore.ReflectType to be emitted inline.
+func WalkReflected(fields []zapcore.Field, walkF func(any)) {
+	for _, field := range fields {
+		if field.Type == zapcore.ReflectType {
+			val := field.Interface
+			if limited, ok := val.(limitedReflected); ok {
+				val = limited.value
+			}
+
+			if _, ok := val.(RawJSON); !ok {
+				walkF(val)
+			}
+
+			continue
+		}
+
+		switch nested := field.Interface.(type) {
+		case Group:
+			WalkReflected(AppendFields(nil, nested...), walkF)
+		case converterGroup:
+			WalkReflected(nested.conv.AppendFields(nil, nested.attrs...), walkF)
+		case Fields:
+			WalkReflected(nested, walkF)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package types
+
+import (
+	"log/slog"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	_ slog.LogValuer = (*lazyValue)(nil)
+	_ slog.LogValuer = (*lazyField)(nil)
+)
+
+type lazyValue struct{ value func() slog.Value }
+
+// Lazy defers value until the attr is encoded, which only happens for records
+// which passed the level check of the core. value is called once for each
+// record the attr is written with, even if the record goes to several cores,
+// so attrs added with WithAttrs are evaluated again for every record. Other
+// handlers resolve it as a slog.LogValuer.
+func Lazy(value func() slog.Value) slog.Value {
+	return slog.AnyValue(&lazyValue{value: value})
+}
+
+func (v *lazyValue) LogValue() slog.Value { return v.value() }
+
+type lazyField struct{ field func() zapcore.Field }
+
+// LazyField is Lazy for a zap field, which brings its own key. The returned
+// attr has an empty key, other handlers inline it as a group.
+func LazyField(field func() zapcore.Field) slog.Attr {
+	return slog.Any("", &lazyField{field: field})
+}
+
+func (f *lazyField) LogValue() slog.Value {
+	got := f.field()
+
+	return slog.GroupValue(slog.Any(got.Key, got))
+}
+
+// lazyMemo keeps what the lazy values of a record evaluated to.
+type lazyMemo struct {
+	sync.Mutex
+	got map[any]any
+}
+
+// memoized returns what eval returns for key, calling it only once for the
+// record of c if it has one.
+func (c Converter) memoized(key any, eval func() any) any {
+	if c.memo == nil {
+		return eval()
+	}
+
+	c.memo.Lock()
+	defer c.memo.Unlock()
+
+	got, ok := c.memo.got[key]
+	if !ok {
+		if c.memo.got == nil {
+			c.memo.got = map[any]any{}
+		}
+
+		got = eval()
+		c.memo.got[key] = got
+	}
+
+	return got
+}
+
+// ForRecord returns c for converting the attrs of a single record, calling
+// each lazy value only once however many times the fields are encoded.
+func (c Converter) ForRecord() Converter {
+	c.memo = &lazyMemo{}
+
+	return c
+}
+
+// Rebind returns fields converted ahead of time, such as those of WithAttrs,
+// with their lazy values and groups evaluated by c. fields is returned as is
+// if nothing changes.
+func (c Converter) Rebind(fields []zapcore.Field) []zapcore.Field {
+	var ret []zapcore.Field
+
+	for i, field := range fields {
+		switch typed := field.Interface.(type) {
+		case lazyMarshaler:
+			typed.conv = c
+			field.Interface = typed
+		case converterGroup:
+			depth := typed.conv.depth
+			typed.conv = c
+			typed.conv.depth = depth
+			field.Interface = typed
+		case Group:
+			field.Interface = c.group(typed)
+		default:
+			if ret != nil {
+				ret = append(ret, field)
+			}
+
+			continue
+		}
+
+		if ret == nil {
+			ret = append(make([]zapcore.Field, 0, len(fields)), fields[:i]...)
+		}
+
+		ret = append(ret, field)
+	}
+
+	if ret == nil {
+		return fields
+	}
+
+	return ret
+}
+
+// IsDeferred reports whether field is evaluated only when it gets encoded,
+// being lazy or a group which may hold lazy values, so it is to be passed
+// through Rebind for each record.
+func IsDeferred(field zapcore.Field) bool {
+	switch field.Interface.(type) {
+	case lazyMarshaler, converterGroup, Group:
+		return true
+	default:
+		return false
+	}
+}
+
+// lazyMarshaler adds a lazy attr inline with its key when it gets encoded.
+type lazyMarshaler struct {
+	conv  Converter
+	key   string
+	value *lazyValue
+	field *lazyField
+}
+
+func (m lazyMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if m.field != nil {
+		field, _ := m.conv.memoized(m.field, func() any { return m.field.field() }).(zapcore.Field)
+		m.conv.Limits.apply(FieldType{
+			Type:      field.Type,
+			Integer:   field.Integer,
+			String:    field.String,
+			Interface: field.Interface,
+		}).Field(field.Key).AddTo(enc)
+
+		return nil
+	}
+
+	// The attr rules apply to what the value turns out to be.
+	value, _ := m.conv.memoized(m.value, func() any { return m.value.value() }).(slog.Value)
+	m.conv.eachField(slog.Attr{Key: m.key, Value: value}, func(f zapcore.Field) { f.AddTo(enc) })
+
+	return nil
+}
+
+// lazy returns the deferred field of a lazy attr.
+func (c Converter) lazy(attr slog.Attr) (zapcore.Field, bool) {
+	if attr.Value.Kind() != slog.KindLogValuer {
+		return zapcore.Field{}, false
+	}
+
+	marshaler := lazyMarshaler{conv: c, key: attr.Key}
+
+	switch typed := attr.Value.LogValuer().(type) {
+	case *lazyValue:
+		marshaler.value = typed
+	case *lazyField:
+		marshaler.field = typed
+	default:
+		return zapcore.Field{}, false
+	}
+
+	return zapcore.Field{Key: attr.Key, Type: zapcore.InlineMarshalerType, Interface: marshaler}, true
+}
+
+// IsLazy reports whether field holds a Lazy or LazyField value, which must not
+// be encoded ahead of time.
+func IsLazy(field zapcore.Field) bool {
+	_, ok := field.Interface.(lazyMarshaler)
+
+	return ok && field.Type == zapcore.InlineMarshalerType
+}
@@ -0,0 +1,336 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Truncated marks strings and arrays cut by Limits, and stands in for groups
+// nested too deep.
+const Truncated = "…"
+
+// Limits bounds the size of converted values. Zero values are unlimited.
+type Limits struct {
+	// MaxString is the maximum length of strings in bytes. It applies to byte
+	// strings, binary values, and the text of errors, fmt.Stringers and
+	// reflected values encoded as JSON as well.
+	MaxString int
+	// MaxArray is the maximum number of array elements.
+	MaxArray int
+	// MaxDepth is the maximum nesting of groups within a value.
+	MaxDepth int
+	// MaxAttrs is the maximum number of attrs of a record. It is enforced by
+	// the handler, which adds a field with the count of the dropped ones.
+	MaxAttrs int
+}
+
+func (l Limits) apply(got FieldType) FieldType { //nolint:cyclop
+	switch got.Type { //nolint:exhaustive
+	case zapcore.StringType:
+		got.String = l.truncate(got.String)
+	case zapcore.ArrayMarshalerType:
+		if arr, ok := got.Interface.(zapcore.ArrayMarshaler); ok && l.MaxArray > 0 {
+			if _, limited := arr.(limitedArray); !limited {
+				got.Interface = limitedArray{ArrayMarshaler: arr, limits: l}
+			}
+		}
+	}
+
+	if l.MaxString < 1 {
+		return got
+	}
+
+	switch typed := got.Interface.(type) {
+	case []byte:
+		if got.Type == zapcore.ByteStringType {
+			got.Interface = l.truncateBytes(typed)
+		} else if got.Type == zapcore.BinaryType && len(typed) > l.MaxString {
+			got.Interface = typed[:l.MaxString:l.MaxString]
+		}
+	case limitedError, limitedStringer, limitedReflected:
+	case error:
+		// zap writes nil pointers as "<nil>", which the wrapper would defeat.
+		if got.Type == zapcore.ErrorType && !isNil(typed) {
+			got.Interface = limitedError{err: typed, limits: l}
+		}
+	case fmt.Stringer:
+		if got.Type == zapcore.StringerType && !isNil(typed) {
+			got.Interface = limitedStringer{Stringer: typed, limits: l}
+		}
+	case RawJSON:
+		// Cut JSON is no JSON, so it goes as a string.
+		if got.Type == zapcore.ReflectType && len(typed) > l.MaxString {
+			got = FieldType{Type: zapcore.StringType, String: l.truncate(string(typed))}
+		}
+	case nil:
+	default:
+		if got.Type == zapcore.ReflectType {
+			got.Interface = limitedReflected{value: typed, limits: l}
+		}
+	}
+
+	return got
+}
+
+func (l Limits) truncateBytes(b []byte) []byte {
+	if l.MaxString < 1 || len(b) <= l.MaxString {
+		return b
+	}
+
+	return []byte(l.truncate(string(b)))
+}
+
+func (l Limits) truncate(s string) string {
+	if l.MaxString < 1 || len(s) <= l.MaxString {
+		return s
+	}
+
+	cut := l.MaxString
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+
+	return s[:cut] + Truncated
+}
+
+type limitedArray struct {
+	zapcore.ArrayMarshaler
+	limits Limits
+}
+
+func (a limitedArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	limited := &limitedArrayEncoder{ArrayEncoder: enc, left: a.limits.MaxArray, limits: a.limits}
+	if err := a.ArrayMarshaler.MarshalLogArray(limited); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if limited.dropped {
+		enc.AppendString(Truncated)
+	}
+
+	return nil
+}
+
+// limitedArrayEncoder drops every element after the first ones.
+type limitedArrayEncoder struct {
+	zapcore.ArrayEncoder
+	left    int
+	dropped bool
+	limits  Limits
+}
+
+func (e *limitedArrayEncoder) take() bool {
+	if e.left < 1 {
+		e.dropped = true
+
+		return false
+	}
+
+	e.left--
+
+	return true
+}
+
+func (e *limitedArrayEncoder) AppendBool(v bool) {
+	if e.take() {
+		e.ArrayEncoder.AppendBool(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendByteString(v []byte) {
+	if e.take() {
+		e.ArrayEncoder.AppendByteString(e.limits.truncateBytes(v))
+	}
+}
+
+func (e *limitedArrayEncoder) AppendComplex128(v complex128) {
+	if e.take() {
+		e.ArrayEncoder.AppendComplex128(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendComplex64(v complex64) {
+	if e.take() {
+		e.ArrayEncoder.AppendComplex64(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendFloat64(v float64) {
+	if e.take() {
+		e.ArrayEncoder.AppendFloat64(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendFloat32(v float32) {
+	if e.take() {
+		e.ArrayEncoder.AppendFloat32(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendInt(v int) {
+	if e.take() {
+		e.ArrayEncoder.AppendInt(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendInt64(v int64) {
+	if e.take() {
+		e.ArrayEncoder.AppendInt64(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendInt32(v int32) {
+	if e.take() {
+		e.ArrayEncoder.AppendInt32(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendInt16(v int16) {
+	if e.take() {
+		e.ArrayEncoder.AppendInt16(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendInt8(v int8) {
+	if e.take() {
+		e.ArrayEncoder.AppendInt8(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendString(v string) {
+	if e.take() {
+		e.ArrayEncoder.AppendString(e.limits.truncate(v))
+	}
+}
+
+func (e *limitedArrayEncoder) AppendUint(v uint) {
+	if e.take() {
+		e.ArrayEncoder.AppendUint(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendUint64(v uint64) {
+	if e.take() {
+		e.ArrayEncoder.AppendUint64(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendUint32(v uint32) {
+	if e.take() {
+		e.ArrayEncoder.AppendUint32(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendUint16(v uint16) {
+	if e.take() {
+		e.ArrayEncoder.AppendUint16(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendUint8(v uint8) {
+	if e.take() {
+		e.ArrayEncoder.AppendUint8(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendUintptr(v uintptr) {
+	if e.take() {
+		e.ArrayEncoder.AppendUintptr(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendDuration(v time.Duration) {
+	if e.take() {
+		e.ArrayEncoder.AppendDuration(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendTime(v time.Time) {
+	if e.take() {
+		e.ArrayEncoder.AppendTime(v)
+	}
+}
+
+func (e *limitedArrayEncoder) AppendArray(v zapcore.ArrayMarshaler) error {
+	if !e.take() {
+		return nil
+	}
+
+	return e.ArrayEncoder.AppendArray(limitedArray{ArrayMarshaler: v, limits: e.limits}) //nolint:wrapcheck
+}
+
+func (e *limitedArrayEncoder) AppendObject(v zapcore.ObjectMarshaler) error {
+	if !e.take() {
+		return nil
+	}
+
+	return e.ArrayEncoder.AppendObject(v) //nolint:wrapcheck
+}
+
+func (e *limitedArrayEncoder) AppendReflected(v any) error {
+	if !e.take() {
+		return nil
+	}
+
+	if e.limits.MaxString > 0 && v != nil {
+		v = limitedReflected{value: v, limits: e.limits}
+	}
+
+	return e.ArrayEncoder.AppendReflected(v) //nolint:wrapcheck
+}
+
+// limitedError cuts the message of an error. Its verbose form is left out.
+type limitedError struct {
+	err    error
+	limits Limits
+}
+
+func (e limitedError) Error() string { return e.limits.truncate(e.err.Error()) }
+
+func (e limitedError) Unwrap() error { return e.err }
+
+// limitedStringer cuts the text of a fmt.Stringer.
+type limitedStringer struct {
+	fmt.Stringer
+	limits Limits
+}
+
+func (s limitedStringer) String() string { return s.limits.truncate(s.Stringer.String()) }
+
+// limitedReflected cuts the JSON of a reflected value, which then goes as a
+// string. It only works with reflected encoders using JSON: others, and
+// encoders keeping values as they are like zapcore.MapObjectEncoder, get the
+// limitedReflected itself.
+type limitedReflected struct {
+	value  any
+	limits Limits
+}
+
+func (r limitedReflected) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(r.value); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	got := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	if len(got) <= r.limits.MaxString {
+		return got, nil
+	}
+
+	buf.Reset()
+
+	if err := enc.Encode(r.limits.truncate(string(got))); err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,78 @@
+package types
+
+import (
+	"maps"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+type converters map[reflect.Type]func(any) FieldType
+
+// Registry holds converters for Go types, consulted ahead of the built-in
+// conversions. It is safe for concurrent use.
+type Registry struct {
+	mu         sync.Mutex
+	converters atomic.Pointer[converters]
+}
+
+var defaultRegistry = NewRegistry() //nolint:gochecknoglobals
+
+func NewRegistry() *Registry { return &Registry{} }
+
+// Add makes reg convert values of type T with convert. T has to be a
+// concrete type, as values are looked up by their dynamic type.
+func Add[T any](reg *Registry, convert func(T) FieldType) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	// Lookups never lock, so the map is replaced instead of modified.
+	next := converters{}
+	if current := reg.converters.Load(); current != nil {
+		next = maps.Clone(*current)
+	}
+
+	next[reflect.TypeOf((*T)(nil)).Elem()] = func(val any) FieldType {
+		typed, _ := val.(T)
+
+		return convert(typed)
+	}
+
+	reg.converters.Store(&next)
+}
+
+// Register makes every conversion turn values of type T into fields with
+// convert, unless a Converter registry has its own converter for T.
+func Register[T any](convert func(T) FieldType) { Add(defaultRegistry, convert) }
+
+func (reg *Registry) lookup(val any) (FieldType, bool) {
+	current := reg.converters.Load()
+	if current == nil || val == nil {
+		return FieldType{}, false
+	}
+
+	if conv, ok := (*current)[reflect.TypeOf(val)]; ok {
+		return conv(val), true
+	}
+
+	return FieldType{}, false
+}
+
+// Converter converts slog values into fields, consulting its Registry and
+// then the one of Register ahead of the built-in conversions.
+type Converter struct {
+	Registry *Registry
+	Limits   Limits
+	depth    int
+	memo     *lazyMemo
+}
+
+func (c Converter) lookup(val any) (FieldType, bool) {
+	if c.Registry != nil {
+		if got, ok := c.Registry.lookup(val); ok {
+			return got, true
+		}
+	}
+
+	return defaultRegistry.lookup(val)
+}
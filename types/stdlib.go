@@ -0,0 +1,80 @@
+package types
+
+import (
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func stringType(s string) FieldType { return FieldType{Type: zapcore.StringType, String: s} }
+
+// handleStdlib converts standard library types which would otherwise end up
+// as lazy stringers, binary or reflected values. Nil pointers are left to the
+// generic conversions.
+func handleStdlib(val any) (FieldType, bool) { //nolint:cyclop
+	switch typed := val.(type) {
+	case netip.Addr:
+		return stringType(typed.String()), true
+	case netip.Prefix:
+		return stringType(typed.String()), true
+	case net.IP:
+		return stringType(typed.String()), true
+	case *url.URL:
+		if typed != nil {
+			return stringType(typed.Redacted()), true
+		}
+	case time.Month:
+		return stringType(typed.String()), true
+	case time.Weekday:
+		return stringType(typed.String()), true
+	case os.FileMode:
+		return stringType(typed.String()), true
+	case *big.Int:
+		if typed != nil {
+			return stringType(typed.String()), true
+		}
+	case *big.Float:
+		if typed != nil {
+			return stringType(typed.Text('g', -1)), true
+		}
+	case json.RawMessage:
+		return RawJSON(typed).FieldType(), true
+	case http.Header:
+		return FieldType{Type: zapcore.ObjectMarshalerType, Interface: Header(typed)}, true
+	}
+
+	return FieldType{}, false
+}
+
+var _ zapcore.ObjectMarshaler = Header(nil)
+
+// Header marshals HTTP headers as an object of string arrays.
+type Header http.Header
+
+func (h Header) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+
+	// Sorted for a stable output, as http.Header.Write does.
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		values, _ := arrayMarshaler(zap.Strings, h[key])
+		if err := enc.AddArray(key, values); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return nil
+}
@@ -31,6 +31,8 @@ func handleAny(val any) (FieldType, bool) {
 	out := FieldType{Interface: val}
 
 	switch typed := val.(type) {
+	case FieldType:
+		return typed, true
 	case zapcore.Field:
 		return FieldType{
 			Type:      typed.Type,
@@ -72,7 +74,7 @@ func isNil(val any) bool {
 	}
 }
 
-func handleReflect(val reflect.Value) (FieldType, bool) {
+func (c Converter) handleReflect(val reflect.Value) (FieldType, bool) {
 	if val.Kind() == reflect.Pointer {
 		if val.IsNil() {
 			return FieldType{Type: zapcore.ReflectType}, true
@@ -80,16 +82,24 @@ func handleReflect(val reflect.Value) (FieldType, bool) {
 
 		switch v := val.Elem().Interface().(type) {
 		case slog.LogValuer:
-			return NewFieldType(v.LogValue()), true
+			return c.FieldType(v.LogValue()), true
 		default:
-			return NewFieldType(slog.AnyValue(v)), true
+			return c.FieldType(slog.AnyValue(v)), true
 		}
 	}
 
 	return FieldType{}, false
 }
 
-func anyType(val any) FieldType {
+func (c Converter) anyType(val any) FieldType {
+	if got, ok := c.lookup(val); ok {
+		return got
+	}
+
+	if got, ok := handleStdlib(val); ok {
+		return got
+	}
+
 	if got, ok := handleAny(val); ok {
 		return got
 	}
@@ -98,23 +108,33 @@ func anyType(val any) FieldType {
 		return Array{got}.FieldType()
 	}
 
-	if got, ok := handleReflect(reflect.ValueOf(val)); ok {
+	if got, ok := c.handleReflect(reflect.ValueOf(val)); ok {
 		return got
 	}
 
 	return FieldType{Type: zapcore.ReflectType, Interface: val}
 }
 
-func logValuerType(val slog.LogValuer) FieldType {
+func (c Converter) logValuerType(val slog.LogValuer) FieldType {
 	if isNil(val) {
 		return FieldType{Type: zapcore.ReflectType}
 	}
 
-	if got, ok := val.LogValue().Any().(FieldType); ok {
+	if got, ok := c.lookup(val); ok {
 		return got
 	}
 
-	return NewFieldType(val.LogValue())
+	return c.FieldType(slog.AnyValue(val).Resolve())
+}
+
+// resolve is slog.Value.Resolve with nil LogValuers left untouched, so they
+// keep converting to a nil reflected field instead of a panic message.
+func resolve(val slog.Value) slog.Value {
+	if val.Kind() == slog.KindLogValuer && isNil(val.LogValuer()) {
+		return val
+	}
+
+	return val.Resolve()
 }
 
 func timeType(t time.Time) FieldType {
@@ -133,12 +153,24 @@ func boolToInt(b bool) int64 {
 	return 0
 }
 
-func NewFieldType(val slog.Value) FieldType { //nolint:cyclop
+func NewFieldType(val slog.Value) FieldType {
+	return Converter{}.FieldType(val)
+}
+
+func (c Converter) FieldType(val slog.Value) FieldType {
+	if c.Limits == (Limits{}) {
+		return c.fieldType(val)
+	}
+
+	return c.Limits.apply(c.fieldType(val))
+}
+
+func (c Converter) fieldType(val slog.Value) FieldType { //nolint:cyclop
 	switch val.Kind() {
 	case slog.KindLogValuer:
-		return logValuerType(val.LogValuer())
+		return c.logValuerType(val.LogValuer())
 	case slog.KindAny:
-		return anyType(val.Any())
+		return c.anyType(val.Any())
 	case slog.KindBool:
 		return FieldType{Type: zapcore.BoolType, Integer: boolToInt(val.Bool())}
 	case slog.KindDuration:
@@ -154,7 +186,11 @@ func NewFieldType(val slog.Value) FieldType { //nolint:cyclop
 	case slog.KindUint64:
 		return FieldType{Type: zapcore.Uint64Type, Integer: int64(val.Uint64())}
 	case slog.KindGroup:
-		return FieldType{Type: zapcore.ObjectMarshalerType, Interface: Group(val.Group())}
+		if c.Limits.MaxDepth > 0 && c.depth >= c.Limits.MaxDepth {
+			return FieldType{Type: zapcore.StringType, String: Truncated}
+		}
+
+		return FieldType{Type: zapcore.ObjectMarshalerType, Interface: c.group(val.Group())}
 	}
 
 	panic("not reachable")
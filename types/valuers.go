@@ -1,6 +1,8 @@
 package types
 
 import (
+	"encoding/json"
+	"io"
 	"log/slog"
 	"math"
 
@@ -94,3 +96,79 @@ func (v Array) LogValue() slog.Value {
 func Skip() slog.Attr {
 	return slog.Any("", zapcore.Field{Type: zapcore.SkipType})
 }
+
+var _ zapcore.ObjectMarshaler = (*Source)(nil)
+
+// Source marshals a slog.Source with the keys of its JSON form.
+type Source slog.Source
+
+func (s *Source) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if s.Function != "" {
+		enc.AddString("function", s.Function)
+	}
+
+	enc.AddString("file", s.File)
+	enc.AddInt("line", s.Line)
+
+	return nil
+}
+
+var (
+	_ slog.LogValuer = RawJSON(nil)
+	_ json.Marshaler = RawJSON(nil)
+)
+
+// RawJSON is already encoded JSON, emitted inline by encoders using JSON for
+// reflected values, console ones included. Encoders whose EncoderConfig has
+// ConsoleReflectedEncoder as NewReflectedEncoder emit it as an escaped string
+// instead. Invalid JSON is emitted as a string as well. RawJSON is not reported as a reflection
+// fallback, even though it goes through zapcore.ReflectType to get there.
+type RawJSON []byte
+
+func (v RawJSON) FieldType() FieldType {
+	if !json.Valid(v) {
+		return FieldType{Type: zapcore.StringType, String: string(v)}
+	}
+
+	return FieldType{Type: zapcore.ReflectType, Interface: v}
+}
+
+func (v RawJSON) LogValue() slog.Value {
+	return slog.AnyValue(v.FieldType())
+}
+
+func (v RawJSON) MarshalJSON() ([]byte, error) {
+	if !json.Valid(v) {
+		return json.Marshal(string(v)) //nolint:wrapcheck
+	}
+
+	return v, nil
+}
+
+var _ zapcore.ReflectedEncoder = consoleReflected{}
+
+// ConsoleReflectedEncoder is a zapcore.EncoderConfig.NewReflectedEncoder for
+// console encoders, which emits RawJSON as an escaped string rather than
+// inline. It has to be set on the EncoderConfig of the encoder, like that of
+// zap.Config. Other values are encoded like zap does by default.
+func ConsoleReflectedEncoder(w io.Writer) zapcore.ReflectedEncoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	return consoleReflected{enc: enc}
+}
+
+type consoleReflected struct{ enc *json.Encoder }
+
+func (e consoleReflected) Encode(val any) error {
+	switch typed := val.(type) {
+	case RawJSON:
+		val = string(typed)
+	case limitedReflected:
+		if raw, ok := typed.value.(RawJSON); ok {
+			val = typed.limits.truncate(string(raw))
+		}
+	}
+
+	return e.enc.Encode(val) //nolint:wrapcheck
+}
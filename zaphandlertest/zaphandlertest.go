@@ -0,0 +1,220 @@
+// Package zaphandlertest provides a zaphandler.ZapHandler writing to memory
+// with stable output, and helpers to assert on what got logged.
+package zaphandlertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mrchanchal.com/zaphandler"
+	"go.uber.org/zap/zapcore"
+)
+
+// UpdateEnv is the environment variable which makes Golden rewrite golden
+// files when set to true. Test packages defining their own bool -update flag
+// can use it as well.
+const UpdateEnv = "ZAPHANDLERTEST_UPDATE"
+
+// Time is the time of every record logged through a handler from New.
+var Time = time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var errNotJSON = errors.New("entries are only available for the JSON format")
+
+type Format int
+
+const (
+	JSON Format = iota
+	Console
+)
+
+type clock time.Time
+
+func (c clock) Now() time.Time                         { return time.Time(c) }
+func (c clock) NewTicker(d time.Duration) *time.Ticker { return time.NewTicker(d) }
+
+// Recorder keeps everything written by a handler from New.
+type Recorder struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	format Format
+}
+
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.buf.Write(p)
+}
+
+func (r *Recorder) Sync() error { return nil }
+
+func (r *Recorder) Bytes() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return bytes.Clone(r.buf.Bytes())
+}
+
+func (r *Recorder) String() string { return string(r.Bytes()) }
+
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.buf.Reset()
+}
+
+// Entries decodes every record written so far. It only works for JSON.
+func (r *Recorder) Entries() ([]map[string]any, error) {
+	if r.format != JSON {
+		return nil, errNotJSON
+	}
+
+	var ret []map[string]any
+
+	for _, line := range bytes.Split(r.Bytes(), []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry map[string]any
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, entry)
+	}
+
+	return ret, nil
+}
+
+// EncoderConfig is the encoder configuration used by New. Callers are only
+// reported by file name, so golden files survive edits moving lines around.
+func EncoderConfig() zapcore.EncoderConfig {
+	return zapcore.EncoderConfig{
+		TimeKey:        "time",
+		LevelKey:       "level",
+		NameKey:        "logger",
+		CallerKey:      "caller",
+		MessageKey:     "msg",
+		StacktraceKey:  "stacktrace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeCaller: func(caller zapcore.EntryCaller, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString(filepath.Base(caller.File))
+		},
+	}
+}
+
+// New returns a handler logging at every level into the returned Recorder,
+// with the time fixed to Time. Options are applied after the defaults.
+func New(format Format, options ...zaphandler.Option) (*zaphandler.ZapHandler, *Recorder) {
+	rec := &Recorder{format: format}
+
+	newEncoder := zapcore.NewJSONEncoder
+	if format == Console {
+		newEncoder = zapcore.NewConsoleEncoder
+	}
+
+	core := zaphandler.NewCore(newEncoder, EncoderConfig(), rec, zapcore.DebugLevel)
+
+	return zaphandler.NewFromCore(core, append([]zaphandler.Option{zaphandler.WithClock(clock(Time))}, options...)...), rec
+}
+
+// AssertLogged reports an error if no record with the message was logged,
+// and returns the first one otherwise.
+func AssertLogged(tb testing.TB, rec *Recorder, msg string) map[string]any {
+	tb.Helper()
+
+	entries, err := rec.Entries()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		if entry[EncoderConfig().MessageKey] == msg {
+			return entry
+		}
+	}
+
+	tb.Errorf("no record logged with message %q", msg)
+
+	return nil
+}
+
+// RequireFields stops the test if entry does not have all the fields with
+// the expected, JSON decoded, values.
+func RequireFields(tb testing.TB, entry map[string]any, fields map[string]any) {
+	tb.Helper()
+
+	for key, expected := range fields {
+		got, ok := entry[key]
+		if !ok {
+			tb.Fatalf("missing field %q in %v", key, entry)
+		}
+
+		if !reflect.DeepEqual(expected, got) {
+			tb.Fatalf("mismatched field %q\nExpected: %#v\nGot:      %#v", key, expected, got)
+		}
+	}
+}
+
+// update reports whether golden files are to be rewritten. The flag is looked
+// up lazily, as this package does not register flags of its own.
+func update() bool {
+	if on, err := strconv.ParseBool(os.Getenv(UpdateEnv)); err == nil {
+		return on
+	}
+
+	if f := flag.Lookup("update"); f != nil {
+		if getter, ok := f.Value.(flag.Getter); ok {
+			on, _ := getter.Get().(bool)
+
+			return on
+		}
+	}
+
+	return false
+}
+
+// Golden compares everything recorded with testdata/<name>.golden, and
+// rewrites the file instead when UpdateEnv is set, or when the tests run with
+// an -update flag defined by the test package.
+func Golden(tb testing.TB, rec *Recorder, name string) {
+	tb.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	got := rec.Bytes()
+
+	if update() {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			tb.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, got, 0o600); err != nil {
+			tb.Fatal(err)
+		}
+
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	if !bytes.Equal(expected, got) {
+		tb.Errorf("mismatched output for %s\nExpected:\n%s\nGot:\n%s", path, expected, got)
+	}
+}
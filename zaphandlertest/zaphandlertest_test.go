@@ -0,0 +1,48 @@
+package zaphandlertest_test
+
+import (
+	"flag"
+	"log/slog"
+	"testing"
+
+	"go.mrchanchal.com/zaphandler"
+	"go.mrchanchal.com/zaphandler/zaphandlertest"
+)
+
+// Test packages defining their own -update flag must not clash with the
+// package.
+var _ = flag.Bool("update", false, "update golden files")
+
+func log(logger *slog.Logger) {
+	logger.Info("first", "string", "value", "int", 1)
+	logger.WithGroup("g").With("a", 1).Warn("second", slog.Group("h", "b", true))
+}
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+
+	hand, rec := zaphandlertest.New(zaphandlertest.JSON, zaphandler.AddSource())
+	log(slog.New(hand))
+
+	entry := zaphandlertest.AssertLogged(t, rec, "second")
+	zaphandlertest.RequireFields(t, entry, map[string]any{
+		"level":  "warn",
+		"caller": "zaphandlertest_test.go",
+		"g":      map[string]any{"a": float64(1), "h": map[string]any{"b": true}},
+	})
+
+	zaphandlertest.Golden(t, rec, "json")
+}
+
+func TestConsole(t *testing.T) {
+	t.Parallel()
+
+	hand, rec := zaphandlertest.New(zaphandlertest.Console)
+	log(slog.New(hand))
+
+	if _, err := rec.Entries(); err == nil {
+		t.Error("expected error decoding console entries")
+	}
+
+	zaphandlertest.Golden(t, rec, "console")
+}